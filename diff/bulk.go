@@ -0,0 +1,55 @@
+package diff
+
+// BulkOp is the operation of a BulkAction.
+type BulkOp string
+
+const (
+	// BulkIndex indexes the document, replacing it if it exists.
+	BulkIndex BulkOp = "index"
+	// BulkDelete deletes the document.
+	BulkDelete BulkOp = "delete"
+)
+
+// BulkAction is an operation of the Bulk API of Elasticsearch that
+// applies a Diff to the destination index, making it match the source.
+type BulkAction struct {
+	Op BulkOp
+	ID string
+	// Index is the index of the document in the destination, if known,
+	// e.g. when the destination is an index pattern or alias. An empty
+	// Index means the index of the request.
+	Index string
+	// Routing is the custom routing of the document, if any.
+	Routing string
+	// Source is the document to index. It is nil for BulkDelete.
+	Source map[string]interface{}
+}
+
+// NewBulkAction returns the action that makes the destination match the
+// source for d: Deleted and Updated documents are indexed with their
+// source, and Created documents are deleted. Updated and Created documents
+// keep the index they have in the destination. If documents are matched by
+// index and ID (keyIndex), Deleted documents are indexed into the index of
+// the same name as in the source. Indexed documents keep the routing they
+// have in the source, and deleted documents the routing they have in the
+// destination. It returns false if the document is Unchanged.
+func NewBulkAction(d Diff, keyIndex bool) (BulkAction, bool) {
+	switch d.Mode {
+	case Deleted, Updated:
+		source := d.Src.Source
+		if source == nil {
+			source = map[string]interface{}{}
+		}
+		var index string
+		if d.Dst != nil {
+			index = d.Dst.Index
+		} else if keyIndex {
+			index = d.Src.Index
+		}
+		return BulkAction{Op: BulkIndex, ID: d.Src.ID, Index: index, Routing: d.Src.Routing, Source: source}, true
+	case Created:
+		return BulkAction{Op: BulkDelete, ID: d.Dst.ID, Index: d.Dst.Index, Routing: d.Dst.Routing}, true
+	default:
+		return BulkAction{}, false
+	}
+}
@@ -0,0 +1,347 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind describes how a single field changed between the source
+// and destination document.
+type ChangeKind int
+
+const (
+	// Added means that a field exists in the destination document,
+	// but not in the source document.
+	Added ChangeKind = iota
+	// Removed means that a field exists in the source document,
+	// but not in the destination document.
+	Removed
+	// Modified means that a field exists in both the source and
+	// destination document, but its value has changed.
+	Modified
+)
+
+// String returns a string representation for a change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "<unspecified>"
+	}
+}
+
+// MarshalJSON encodes the change kind as a JSON string.
+func (k ChangeKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// Path is the path to a field in a document. Its elements are
+// either object keys (strings) or array indices (ints).
+type Path []interface{}
+
+// String returns the path in JSON path notation, e.g. "user.name"
+// or "tags[1]".
+func (p Path) String() string {
+	var sb strings.Builder
+	for _, elem := range p {
+		switch v := elem.(type) {
+		case int:
+			fmt.Fprintf(&sb, "[%d]", v)
+		default:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			fmt.Fprint(&sb, v)
+		}
+	}
+	return sb.String()
+}
+
+// Pointer returns the path as a JSON Pointer as specified in
+// RFC 6901, e.g. "/user/name" or "/tags/1".
+func (p Path) Pointer() string {
+	var sb strings.Builder
+	for _, elem := range p {
+		sb.WriteByte('/')
+		switch v := elem.(type) {
+		case int:
+			sb.WriteString(strconv.Itoa(v))
+		default:
+			s := fmt.Sprint(v)
+			s = strings.ReplaceAll(s, "~", "~0")
+			s = strings.ReplaceAll(s, "/", "~1")
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// MarshalJSON encodes the path as a JSON string in JSON path notation.
+func (p Path) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// Field returns the name of the field the path refers to, i.e. the
+// object keys joined by dots without array indices, e.g. "items.sku"
+// for "items[0].sku".
+func (p Path) Field() string {
+	var sb strings.Builder
+	for _, elem := range p {
+		if _, ok := elem.(int); ok {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		fmt.Fprint(&sb, elem)
+	}
+	return sb.String()
+}
+
+// append returns a copy of p with elem appended.
+func (p Path) append(elem interface{}) Path {
+	q := make(Path, len(p), len(p)+1)
+	copy(q, p)
+	return append(q, elem)
+}
+
+// Change describes a change of a single field between the source
+// and destination document.
+type Change struct {
+	Path Path        `json:"path"`
+	Kind ChangeKind  `json:"kind"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// MarshalJSON encodes the change as a JSON object. It has an "old" value
+// unless the field was added, and a "new" value unless it was removed,
+// even if the value is null.
+func (c Change) MarshalJSON() ([]byte, error) {
+	type change struct {
+		Path Path         `json:"path"`
+		Kind ChangeKind   `json:"kind"`
+		Old  *interface{} `json:"old,omitempty"`
+		New  *interface{} `json:"new,omitempty"`
+	}
+	v := change{Path: c.Path, Kind: c.Kind}
+	if c.Kind != Added {
+		v.Old = &c.Old
+	}
+	if c.Kind != Removed {
+		v.New = &c.New
+	}
+	return json.Marshal(v)
+}
+
+// Compare returns the field-level changes between the source src and
+// destination dst of a document. It returns nil if both are equal.
+//
+// Changes are returned in a stable order: object keys are sorted,
+// and elements removed from the end of an array are listed from the
+// last to the first, so the changes can be applied one by one.
+// See WithUnorderedArrays for the changes of unordered arrays.
+func Compare(src, dst map[string]interface{}, opts ...Option) []Change {
+	return newOptions(opts...).compare(src, dst)
+}
+
+// compare returns the field-level changes between src and dst.
+func (o *options) compare(src, dst map[string]interface{}) []Change {
+	var changes []Change
+	o.compareObjects(nil, src, dst, &changes)
+	return changes
+}
+
+// compareValues compares two arbitrary values at path.
+func (o *options) compareValues(path Path, src, dst interface{}, changes *[]Change) {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		if d, ok := dst.(map[string]interface{}); ok {
+			o.compareObjects(path, s, d, changes)
+			return
+		}
+	case []interface{}:
+		if d, ok := dst.([]interface{}); ok {
+			if unordered, key := o.unorderedArray(path); unordered {
+				o.compareUnorderedArrays(path, key, s, d, changes)
+			} else {
+				o.compareArrays(path, s, d, changes)
+			}
+			return
+		}
+	}
+	if !o.equal(path, src, dst) {
+		*changes = append(*changes, Change{Path: path, Kind: Modified, Old: src, New: dst})
+	}
+}
+
+// compareObjects compares two JSON objects at path.
+func (o *options) compareObjects(path Path, src, dst map[string]interface{}, changes *[]Change) {
+	keys := make([]string, 0, len(src)+len(dst))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	for key := range dst {
+		if _, found := src[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path.append(key)
+		if o.ignored(keyPath) {
+			continue
+		}
+		s, inSrc := src[key]
+		d, inDst := dst[key]
+		switch {
+		case inSrc && inDst:
+			o.compareValues(keyPath, s, d, changes)
+		case inSrc:
+			*changes = append(*changes, Change{Path: keyPath, Kind: Removed, Old: s})
+		default:
+			*changes = append(*changes, Change{Path: keyPath, Kind: Added, New: d})
+		}
+	}
+}
+
+// compareArrays compares two JSON arrays at path, element by element.
+func (o *options) compareArrays(path Path, src, dst []interface{}, changes *[]Change) {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	for i := 0; i < n; i++ {
+		o.compareValues(path.append(i), src[i], dst[i], changes)
+	}
+	for i := n; i < len(dst); i++ {
+		*changes = append(*changes, Change{Path: path.append(i), Kind: Added, New: dst[i]})
+	}
+	for i := len(src) - 1; i >= n; i-- {
+		*changes = append(*changes, Change{Path: path.append(i), Kind: Removed, Old: src[i]})
+	}
+}
+
+// compareUnorderedArrays compares two JSON arrays at path, ignoring the
+// order of their elements. Elements are matched by the value of key if
+// key is not empty and all elements are objects with a unique key, and
+// by being equal otherwise.
+//
+// Matched elements are compared at their index in src. Elements of src
+// without a match are removed from the last to the first, then elements
+// of dst without a match are added to the end.
+func (o *options) compareUnorderedArrays(path Path, key string, src, dst []interface{}, changes *[]Change) {
+	matches, ok := matchByKey(key, src, dst)
+	if !ok {
+		matches = o.matchEqual(path, src, dst)
+	}
+
+	matched := make([]bool, len(dst))
+	for i, j := range matches {
+		if j >= 0 {
+			matched[j] = true
+			o.compareValues(path.append(i), src[i], dst[j], changes)
+		}
+	}
+	n := len(src)
+	for i := len(src) - 1; i >= 0; i-- {
+		if matches[i] < 0 {
+			*changes = append(*changes, Change{Path: path.append(i), Kind: Removed, Old: src[i]})
+			n--
+		}
+	}
+	for j := range dst {
+		if !matched[j] {
+			*changes = append(*changes, Change{Path: path.append(n), Kind: Added, New: dst[j]})
+			n++
+		}
+	}
+}
+
+// matchEqual matches every element of src with an equal element of dst.
+// It returns the index of the matching element in dst for every element
+// in src, or -1 if there is none.
+func (o *options) matchEqual(path Path, src, dst []interface{}) []int {
+	matches := make([]int, len(src))
+	matched := make([]bool, len(dst))
+	for i := range src {
+		matches[i] = -1
+		for j := range dst {
+			if matched[j] {
+				continue
+			}
+			var elemChanges []Change
+			o.compareValues(path.append(i), src[i], dst[j], &elemChanges)
+			if len(elemChanges) == 0 {
+				matches[i] = j
+				matched[j] = true
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// matchByKey matches the elements of src and dst by the value of key,
+// which may be in dot notation. It returns the index of the matching
+// element in dst for every element in src, or -1 if there is none.
+// It returns false if key is empty, or if an element has no unique key.
+func matchByKey(key string, src, dst []interface{}) ([]int, bool) {
+	if key == "" {
+		return nil, false
+	}
+	dstIndex := make(map[string]int, len(dst))
+	for j, elem := range dst {
+		k, ok := elementKey(key, elem)
+		if !ok {
+			return nil, false
+		}
+		if _, found := dstIndex[k]; found {
+			return nil, false
+		}
+		dstIndex[k] = j
+	}
+	matches := make([]int, len(src))
+	seen := make(map[string]bool, len(src))
+	for i, elem := range src {
+		k, ok := elementKey(key, elem)
+		if !ok || seen[k] {
+			return nil, false
+		}
+		seen[k] = true
+		if j, found := dstIndex[k]; found {
+			matches[i] = j
+		} else {
+			matches[i] = -1
+		}
+	}
+	return matches, true
+}
+
+// elementKey returns the value of key in elem, encoded as JSON.
+func elementKey(key string, elem interface{}) (string, bool) {
+	v := elem
+	for _, name := range strings.Split(key, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[name]; !ok {
+			return "", false
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
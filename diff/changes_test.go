@@ -0,0 +1,395 @@
+package diff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var compareTests = []struct {
+	Src, Dst map[string]interface{}
+	Changes  []Change
+}{
+	// #0
+	{
+		Src:     nil,
+		Dst:     map[string]interface{}{},
+		Changes: nil,
+	},
+	// #1
+	{
+		Src:     map[string]interface{}{"name": "One", "tags": []interface{}{"a", "b"}},
+		Dst:     map[string]interface{}{"name": "One", "tags": []interface{}{"a", "b"}},
+		Changes: nil,
+	},
+	// #2
+	{
+		Src: map[string]interface{}{"name": "One", "price": 599.0, "old": true},
+		Dst: map[string]interface{}{"name": "Two", "price": 599.0, "new": false},
+		Changes: []Change{
+			{Path: Path{"name"}, Kind: Modified, Old: "One", New: "Two"},
+			{Path: Path{"new"}, Kind: Added, New: false},
+			{Path: Path{"old"}, Kind: Removed, Old: true},
+		},
+	},
+	// #3
+	{
+		Src: map[string]interface{}{"user": map[string]interface{}{"name": "Oliver", "age": 42.0}},
+		Dst: map[string]interface{}{"user": map[string]interface{}{"name": "Olivere"}},
+		Changes: []Change{
+			{Path: Path{"user", "age"}, Kind: Removed, Old: 42.0},
+			{Path: Path{"user", "name"}, Kind: Modified, Old: "Oliver", New: "Olivere"},
+		},
+	},
+	// #4
+	{
+		Src: map[string]interface{}{"tags": []interface{}{"a", "b", "c", "d"}},
+		Dst: map[string]interface{}{"tags": []interface{}{"a", "x"}},
+		Changes: []Change{
+			{Path: Path{"tags", 1}, Kind: Modified, Old: "b", New: "x"},
+			{Path: Path{"tags", 3}, Kind: Removed, Old: "d"},
+			{Path: Path{"tags", 2}, Kind: Removed, Old: "c"},
+		},
+	},
+	// #5
+	{
+		Src: map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "1"}}},
+		Dst: map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "2"}, "new"}},
+		Changes: []Change{
+			{Path: Path{"items", 0, "sku"}, Kind: Modified, Old: "1", New: "2"},
+			{Path: Path{"items", 1}, Kind: Added, New: "new"},
+		},
+	},
+	// #6
+	{
+		Src: map[string]interface{}{"value": map[string]interface{}{"a": 1.0}},
+		Dst: map[string]interface{}{"value": []interface{}{1.0}},
+		Changes: []Change{
+			{Path: Path{"value"}, Kind: Modified, Old: map[string]interface{}{"a": 1.0}, New: []interface{}{1.0}},
+		},
+	},
+}
+
+func TestCompare(t *testing.T) {
+	for i, tt := range compareTests {
+		if want, have := tt.Changes, Compare(tt.Src, tt.Dst); !cmp.Equal(want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		Path    Path
+		String  string
+		Pointer string
+	}{
+		{Path: nil, String: "", Pointer: ""},
+		{Path: Path{"user", "name"}, String: "user.name", Pointer: "/user/name"},
+		{Path: Path{"items", 0, "sku"}, String: "items[0].sku", Pointer: "/items/0/sku"},
+		{Path: Path{"a/b", "m~n"}, String: "a/b.m~n", Pointer: "/a~1b/m~0n"},
+	}
+	for i, tt := range tests {
+		if want, have := tt.String, tt.Path.String(); want != have {
+			t.Errorf("#%d: String: want %q, have %q", i, want, have)
+		}
+		if want, have := tt.Pointer, tt.Path.Pointer(); want != have {
+			t.Errorf("#%d: Pointer: want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestChangeMarshalJSON(t *testing.T) {
+	tests := []struct {
+		Change Change
+		Want   string
+	}{
+		{Change{Path: Path{"a"}, Kind: Added, New: "x"}, `{"path":"a","kind":"added","new":"x"}`},
+		{Change{Path: Path{"a"}, Kind: Added, New: nil}, `{"path":"a","kind":"added","new":null}`},
+		{Change{Path: Path{"a"}, Kind: Removed, Old: false}, `{"path":"a","kind":"removed","old":false}`},
+		{Change{Path: Path{"a"}, Kind: Removed, Old: nil}, `{"path":"a","kind":"removed","old":null}`},
+		{Change{Path: Path{"a", 1}, Kind: Modified, Old: nil, New: "x"}, `{"path":"a[1]","kind":"modified","old":null,"new":"x"}`},
+		{Change{Path: Path{"a"}, Kind: Modified, Old: 0.0, New: ""}, `{"path":"a","kind":"modified","old":0,"new":""}`},
+	}
+	for i, tt := range tests {
+		data, err := json.Marshal(tt.Change)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if have := string(data); have != tt.Want {
+			t.Errorf("#%d: want %s, have %s", i, tt.Want, have)
+		}
+	}
+}
+
+func TestCompareWithIgnorePaths(t *testing.T) {
+	src := map[string]interface{}{
+		"name":        "One",
+		"@timestamp":  "2022-01-01T00:00:00Z",
+		"ingested_at": "2022-01-01T00:00:00Z",
+		"_meta":       map[string]interface{}{"version": 1.0, "owner": "olivere"},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "1", "checksum": "a"},
+		},
+	}
+	dst := map[string]interface{}{
+		"name":        "One",
+		"@timestamp":  "2022-01-02T00:00:00Z",
+		"ingested_at": "2022-01-02T00:00:00Z",
+		"_meta":       map[string]interface{}{"version": 2.0, "owner": "sandrae"},
+		"items": []interface{}{
+			map[string]interface{}{"sku": "2", "checksum": "b"},
+		},
+	}
+
+	tests := []struct {
+		Patterns []string
+		Changes  []Change
+	}{
+		// #0
+		{
+			Patterns: []string{"@timestamp", "*_at", "_meta", "items"},
+			Changes:  nil,
+		},
+		// #1
+		{
+			Patterns: []string{"@timestamp", "ingested_at", "_meta.version", "**.checksum"},
+			Changes: []Change{
+				{Path: Path{"_meta", "owner"}, Kind: Modified, Old: "olivere", New: "sandrae"},
+				{Path: Path{"items", 0, "sku"}, Kind: Modified, Old: "1", New: "2"},
+			},
+		},
+		// #2
+		{
+			Patterns: []string{"**", "name"},
+			Changes:  nil,
+		},
+		// #3
+		{
+			Patterns: []string{"*.*", "@timestamp", "ingested_at"},
+			Changes:  nil,
+		},
+		// #4
+		{
+			Patterns: []string{"_meta.*", "items.sku", "*_at", "@*"},
+			Changes: []Change{
+				{Path: Path{"items", 0, "checksum"}, Kind: Modified, Old: "a", New: "b"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		have := Compare(src, dst, WithIgnorePaths(tt.Patterns...))
+		if want := tt.Changes; !cmp.Equal(want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
+
+func TestCompareWithComparisonRules(t *testing.T) {
+	a, b := 0.1, 0.2
+	noisy := a + b
+
+	tests := []struct {
+		Src, Dst map[string]interface{}
+		Options  []Option
+		Changes  []Change
+	}{
+		// #0 Numbers are compared by value
+		{
+			Src:     map[string]interface{}{"count": 1, "price": float32(2.5)},
+			Dst:     map[string]interface{}{"count": 1.0, "price": 2.5},
+			Changes: nil,
+		},
+		// #1 Floating point noise without tolerance
+		{
+			Src: map[string]interface{}{"price": noisy},
+			Dst: map[string]interface{}{"price": 0.3},
+			Changes: []Change{
+				{Path: Path{"price"}, Kind: Modified, Old: noisy, New: 0.3},
+			},
+		},
+		// #2 Floating point noise with tolerance
+		{
+			Src:     map[string]interface{}{"price": noisy},
+			Dst:     map[string]interface{}{"price": 0.3},
+			Options: []Option{WithNumericTolerance(1e-9)},
+			Changes: nil,
+		},
+		// #3 Tolerance restricted to fields
+		{
+			Src:     map[string]interface{}{"price": 10.0, "items": []interface{}{map[string]interface{}{"amount": 1.0}}},
+			Dst:     map[string]interface{}{"price": 10.5, "items": []interface{}{map[string]interface{}{"amount": 1.5}}},
+			Options: []Option{WithNumericTolerance(1, "items.amount")},
+			Changes: []Change{
+				{Path: Path{"price"}, Kind: Modified, Old: 10.0, New: 10.5},
+			},
+		},
+		// #4 No coercion by default
+		{
+			Src: map[string]interface{}{"id": "42"},
+			Dst: map[string]interface{}{"id": 42.0},
+			Changes: []Change{
+				{Path: Path{"id"}, Kind: Modified, Old: "42", New: 42.0},
+			},
+		},
+		// #5 Coercion
+		{
+			Src:     map[string]interface{}{"id": "42", "count": "1.0", "name": "x"},
+			Dst:     map[string]interface{}{"id": 42.0, "count": 1.0, "name": 1.0},
+			Options: []Option{WithCoercion()},
+			Changes: []Change{
+				{Path: Path{"name"}, Kind: Modified, Old: "x", New: 1.0},
+			},
+		},
+		// #6 Coercion restricted to fields
+		{
+			Src:     map[string]interface{}{"id": "42", "count": "1"},
+			Dst:     map[string]interface{}{"id": 42.0, "count": 1.0},
+			Options: []Option{WithCoercion("id")},
+			Changes: []Change{
+				{Path: Path{"count"}, Kind: Modified, Old: "1", New: 1.0},
+			},
+		},
+		// #7 Date normalization
+		{
+			Src: map[string]interface{}{
+				"created":  "2022-01-01T10:00:00Z",
+				"updated":  "2022-01-01T10:00:00.123Z",
+				"day":      "2022-01-01",
+				"epoch":    1641031200000.0,
+				"modified": "2022-01-01T10:00:00Z",
+			},
+			Dst: map[string]interface{}{
+				"created":  "2022-01-01T11:00:00.000+01:00",
+				"updated":  "2022-01-01T10:00:00.123000Z",
+				"day":      "2022-01-01T00:00:00Z",
+				"epoch":    "2022-01-01T10:00:00Z",
+				"modified": "2022-01-01T10:00:01Z",
+			},
+			Options: []Option{WithDateNormalization()},
+			Changes: []Change{
+				{Path: Path{"modified"}, Kind: Modified, Old: "2022-01-01T10:00:00Z", New: "2022-01-01T10:00:01Z"},
+			},
+		},
+		// #8 Date normalization restricted to fields
+		{
+			Src:     map[string]interface{}{"created": "2022-01-01T10:00:00Z", "name": "2022-01-01"},
+			Dst:     map[string]interface{}{"created": "2022-01-01T10:00:00.000Z", "name": "2022-01-01T00:00:00Z"},
+			Options: []Option{WithDateNormalization("*_at", "created")},
+			Changes: []Change{
+				{Path: Path{"name"}, Kind: Modified, Old: "2022-01-01", New: "2022-01-01T00:00:00Z"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		have := Compare(tt.Src, tt.Dst, tt.Options...)
+		if want := tt.Changes; !cmp.Equal(want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
+
+func TestCompareWithUnorderedArrays(t *testing.T) {
+	tests := []struct {
+		Src, Dst map[string]interface{}
+		Options  []Option
+		Changes  []Change
+	}{
+		// #0 Arrays are ordered by default
+		{
+			Src: map[string]interface{}{"tags": []interface{}{"a", "b"}},
+			Dst: map[string]interface{}{"tags": []interface{}{"b", "a"}},
+			Changes: []Change{
+				{Path: Path{"tags", 0}, Kind: Modified, Old: "a", New: "b"},
+				{Path: Path{"tags", 1}, Kind: Modified, Old: "b", New: "a"},
+			},
+		},
+		// #1 Unordered arrays
+		{
+			Src:     map[string]interface{}{"tags": []interface{}{"a", "b", map[string]interface{}{"x": 1.0}}},
+			Dst:     map[string]interface{}{"tags": []interface{}{map[string]interface{}{"x": 1}, "b", "a"}},
+			Options: []Option{WithUnorderedArrays()},
+			Changes: nil,
+		},
+		// #2 Unordered arrays are multisets
+		{
+			Src:     map[string]interface{}{"tags": []interface{}{"a", "a", "b", "c"}},
+			Dst:     map[string]interface{}{"tags": []interface{}{"b", "a", "d", "e"}},
+			Options: []Option{WithUnorderedArrays("tags")},
+			Changes: []Change{
+				{Path: Path{"tags", 3}, Kind: Removed, Old: "c"},
+				{Path: Path{"tags", 1}, Kind: Removed, Old: "a"},
+				{Path: Path{"tags", 2}, Kind: Added, New: "d"},
+				{Path: Path{"tags", 3}, Kind: Added, New: "e"},
+			},
+		},
+		// #3 Unordered arrays restricted to fields
+		{
+			Src:     map[string]interface{}{"tags": []interface{}{"a", "b"}, "list": []interface{}{"a", "b"}},
+			Dst:     map[string]interface{}{"tags": []interface{}{"b", "a"}, "list": []interface{}{"b", "a"}},
+			Options: []Option{WithUnorderedArrays("tags")},
+			Changes: []Change{
+				{Path: Path{"list", 0}, Kind: Modified, Old: "a", New: "b"},
+				{Path: Path{"list", 1}, Kind: Modified, Old: "b", New: "a"},
+			},
+		},
+		// #4 Arrays matched by key
+		{
+			Src: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"sku": "1", "qty": 1.0},
+				map[string]interface{}{"sku": "2", "qty": 2.0},
+				map[string]interface{}{"sku": "3", "qty": 3.0},
+			}},
+			Dst: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"sku": "4", "qty": 4.0},
+				map[string]interface{}{"sku": "3", "qty": 3.0},
+				map[string]interface{}{"sku": "1", "qty": 5.0},
+			}},
+			Options: []Option{WithUnorderedArrays(), WithArrayKey("sku", "items")},
+			Changes: []Change{
+				{Path: Path{"items", 0, "qty"}, Kind: Modified, Old: 1.0, New: 5.0},
+				{Path: Path{"items", 1}, Kind: Removed, Old: map[string]interface{}{"sku": "2", "qty": 2.0}},
+				{Path: Path{"items", 2}, Kind: Added, New: map[string]interface{}{"sku": "4", "qty": 4.0}},
+			},
+		},
+		// #5 Arrays matched by a nested key
+		{
+			Src: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"ref": map[string]interface{}{"id": 1.0}, "name": "One"},
+				map[string]interface{}{"ref": map[string]interface{}{"id": 2.0}, "name": "Two"},
+			}},
+			Dst: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"ref": map[string]interface{}{"id": 2.0}, "name": "Two"},
+				map[string]interface{}{"ref": map[string]interface{}{"id": 1.0}, "name": "One"},
+			}},
+			Options: []Option{WithArrayKey("ref.id")},
+			Changes: nil,
+		},
+		// #6 Duplicate keys fall back to unordered arrays
+		{
+			Src: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"sku": "1", "qty": 1.0},
+				map[string]interface{}{"sku": "1", "qty": 2.0},
+			}},
+			Dst: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"sku": "1", "qty": 2.0},
+				map[string]interface{}{"sku": "1", "qty": 3.0},
+			}},
+			Options: []Option{WithArrayKey("sku", "items")},
+			Changes: []Change{
+				{Path: Path{"items", 0}, Kind: Removed, Old: map[string]interface{}{"sku": "1", "qty": 1.0}},
+				{Path: Path{"items", 1}, Kind: Added, New: map[string]interface{}{"sku": "1", "qty": 3.0}},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		have := Compare(tt.Src, tt.Dst, tt.Options...)
+		if want := tt.Changes; !cmp.Equal(want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
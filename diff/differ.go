@@ -2,14 +2,39 @@ package diff
 
 import (
 	"context"
-
-	"github.com/google/go-cmp/cmp"
+	"fmt"
 )
 
 // Document is a generic document retrieved from Elasticsearch.
 type Document struct {
-	ID     string                 `json:"_id,omitempty"`
-	Source map[string]interface{} `json:"_source,omitempty"`
+	ID      string                 `json:"_id,omitempty"`
+	Index   string                 `json:"_index,omitempty"`
+	Routing string                 `json:"_routing,omitempty"`
+	Source  map[string]interface{} `json:"_source,omitempty"`
+	// Key orders and matches documents instead of the ID if it is set,
+	// e.g. to compare numeric IDs in numeric order.
+	Key string `json:"-"`
+	// OriginalID is the _id of the document if ID was replaced by the
+	// value of other fields, e.g. with -replace-with.
+	OriginalID string `json:"-"`
+}
+
+// Name returns the ID of the document, prefixed with its index if it has
+// one, e.g. "logs-2024.01.01/1". It identifies the document in messages.
+func (d *Document) Name() string {
+	if d.Index != "" {
+		return d.Index + "/" + d.ID
+	}
+	return d.ID
+}
+
+// SortKey returns the key that orders and matches documents, i.e.
+// the Key if set, or the ID otherwise.
+func (d *Document) SortKey() string {
+	if d.Key != "" {
+		return d.Key
+	}
+	return d.ID
 }
 
 // Mode describes the outcome of comparing two documents.
@@ -52,16 +77,25 @@ type Diff struct {
 	Mode Mode
 	Src  *Document
 	Dst  *Document
+	// Changes lists the field-level changes of Updated documents.
+	Changes []Change
 }
 
 // Differ compares the documents in the source index to those in
 // the destination index. It returns the outcomes via a Diff structure,
-// one by one.
+// one by one. Use opts to configure how documents are compared.
+//
+// Both source and destination must return the documents sorted by key
+// (see Document.SortKey) in ascending order, with every key occurring
+// only once. Differ stops with a SortOrderError otherwise; use HashDiffer
+// for documents that are not sorted.
 func Differ(
 	ctx context.Context,
 	srcCh <-chan *Document,
 	dstCh <-chan *Document,
+	opts ...Option,
 ) (<-chan Diff, <-chan error) {
+	o := newOptions(opts...)
 	diffCh := make(chan Diff)
 	errCh := make(chan error)
 
@@ -71,153 +105,119 @@ func Differ(
 			close(errCh)
 		}()
 
-		// Both src and dst are nil => no diffs
-		if srcCh == nil && dstCh == nil {
-			return
+		src := &cursor{ch: srcCh, side: "source"}
+		dst := &cursor{ch: dstCh, side: "destination"}
+		if err := o.merge(ctx, src, dst, diffCh); err != nil {
+			errCh <- err
 		}
+	}()
 
-		// No src => return all from dst as Created
-		if srcCh == nil && dstCh != nil {
-			for {
-				select {
-				case doc, ok := <-dstCh:
-					if !ok {
-						return
-					}
-					diffCh <- Diff{Mode: Created, Dst: doc}
-				case <-ctx.Done():
-					errCh <- ctx.Err()
-					return
-				}
-			}
-		}
+	return diffCh, errCh
+}
+
+// merge compares the documents of src and dst, which are both sorted
+// by key, and sends the outcomes to diffCh.
+func (o *options) merge(ctx context.Context, src, dst *cursor, diffCh chan<- Diff) error {
+	srcDoc, err := src.next(ctx)
+	if err != nil {
+		return err
+	}
+	dstDoc, err := dst.next(ctx)
+	if err != nil {
+		return err
+	}
 
-		// No dst => return all from src as Deleted
-		if srcCh != nil && dstCh == nil {
-			for {
-				select {
-				case doc, ok := <-srcCh:
-					if !ok {
-						return
-					}
-					diffCh <- Diff{Mode: Deleted, Src: doc}
-				case <-ctx.Done():
-					errCh <- ctx.Err()
-					return
-				}
+	for srcDoc != nil || dstDoc != nil {
+		var d Diff
+		switch {
+		case dstDoc == nil || (srcDoc != nil && srcDoc.SortKey() < dstDoc.SortKey()):
+			// Only in src => Deleted
+			d = Diff{Mode: Deleted, Src: srcDoc}
+			srcDoc, err = src.next(ctx)
+		case srcDoc == nil || srcDoc.SortKey() > dstDoc.SortKey():
+			// Only in dst => Created
+			d = Diff{Mode: Created, Dst: dstDoc}
+			dstDoc, err = dst.next(ctx)
+		default:
+			// srcDoc.SortKey() == dstDoc.SortKey()
+			if changes := o.compare(srcDoc.Source, dstDoc.Source); len(changes) == 0 {
+				d = Diff{Mode: Unchanged, Src: srcDoc, Dst: dstDoc}
+			} else {
+				d = Diff{Mode: Updated, Src: srcDoc, Dst: dstDoc, Changes: changes}
+			}
+			srcDoc, err = src.next(ctx)
+			if err == nil {
+				dstDoc, err = dst.next(ctx)
 			}
 		}
 
-		// Read first document from both channels
-		srcDoc, dstDoc := <-srcCh, <-dstCh
-
-		// Main loop
-		for {
-			// Stop early because context might be canceled.
-			select {
-			default:
-			case <-ctx.Done():
-				errCh <- ctx.Err()
-				return
-			}
+		select {
+		case diffCh <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			// No more documents from the channels => done
-			if srcDoc == nil && dstDoc == nil {
-				break
-			}
+// SortOrderError is returned by Differ if the documents of the source or
+// destination are not sorted by key in ascending order, or if a key occurs
+// more than once.
+type SortOrderError struct {
+	// Side is either "source" or "destination".
+	Side string
+	// Prev is the name of the previous document, see Document.Name.
+	Prev string
+	// ID is the name of the document that is out of order.
+	ID string
+	// Duplicate is true if both documents have the same key.
+	Duplicate bool
+}
 
-			// No more from dst => everything in src has to be deleted
-			if srcDoc != nil && dstDoc == nil {
-				diffCh <- Diff{Mode: Deleted, Src: srcDoc}
-				for {
-					select {
-					case doc, ok := <-dstCh:
-						if !ok {
-							return
-						}
-						diffCh <- Diff{Mode: Deleted, Src: doc}
-					case <-ctx.Done():
-						errCh <- ctx.Err()
-						return
-					}
-				}
-			}
+// Error returns a description of the error.
+func (e SortOrderError) Error() string {
+	if e.Duplicate {
+		if e.Prev == e.ID {
+			return fmt.Sprintf("duplicate document %q in %s", e.ID, e.Side)
+		}
+		return fmt.Sprintf("duplicate document %q in %s: same key as %q", e.ID, e.Side, e.Prev)
+	}
+	return fmt.Sprintf("documents in %s are not sorted by key: %q follows %q", e.Side, e.ID, e.Prev)
+}
 
-			// No more from src => everything in dst has to be created
-			if srcDoc == nil && dstDoc != nil {
-				diffCh <- Diff{Mode: Created, Dst: dstDoc}
-				for {
-					select {
-					case doc, ok := <-dstCh:
-						if !ok {
-							return
-						}
-						diffCh <- Diff{Mode: Created, Dst: doc}
-					case <-ctx.Done():
-						errCh <- ctx.Err()
-						return
-					}
-				}
-			}
+// cursor reads the documents of one side, making sure they are sorted.
+type cursor struct {
+	ch   <-chan *Document
+	side string
+	last *Document
+}
 
-			// We have two to compare
-			if srcDoc.ID > dstDoc.ID {
-				diffCh <- Diff{Mode: Created, Dst: dstDoc}
-				dstDoc = nil
-				var stop bool
-				for !stop {
-					select {
-					case doc, ok := <-dstCh:
-						if !ok {
-							stop = true
-							break
-						}
-						dstDoc = doc
-						if srcDoc.ID <= dstDoc.ID {
-							stop = true
-							break
-						}
-						diffCh <- Diff{Mode: Created, Dst: dstDoc}
-						dstDoc = nil
-					case <-ctx.Done():
-						errCh <- ctx.Err()
-						return
-					}
-				}
-			} else if srcDoc.ID < dstDoc.ID {
-				diffCh <- Diff{Mode: Deleted, Src: srcDoc}
-				srcDoc = nil
-				var stop bool
-				for !stop {
-					select {
-					case doc, ok := <-srcCh:
-						if !ok {
-							stop = true
-							break
-						}
-						srcDoc = doc
-						if srcDoc.ID >= dstDoc.ID {
-							stop = true
-							break
-						}
-						diffCh <- Diff{Mode: Deleted, Src: srcDoc}
-						srcDoc = nil
-					case <-ctx.Done():
-						errCh <- ctx.Err()
-						return
-					}
-				}
-			} else {
-				// srcDoc.ID == dstDoc.ID
-				if cmp.Equal(srcDoc.Source, dstDoc.Source) {
-					diffCh <- Diff{Mode: Unchanged, Src: srcDoc, Dst: dstDoc}
-				} else {
-					diffCh <- Diff{Mode: Updated, Src: srcDoc, Dst: dstDoc}
-				}
-				srcDoc, dstDoc = <-srcCh, <-dstCh
+// next returns the next document, or nil if there are no more documents.
+// A nil channel has no documents.
+func (c *cursor) next(ctx context.Context) (*Document, error) {
+	if c.ch == nil {
+		return nil, nil
+	}
+	select {
+	case doc, ok := <-c.ch:
+		if !ok {
+			c.ch = nil
+			return nil, nil
+		}
+		if c.last != nil && doc.SortKey() <= c.last.SortKey() {
+			return nil, SortOrderError{
+				Side:      c.side,
+				Prev:      c.last.Name(),
+				ID:        doc.Name(),
+				Duplicate: doc.SortKey() == c.last.SortKey(),
 			}
 		}
-	}()
-
-	return diffCh, errCh
+		c.last = doc
+		return doc, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
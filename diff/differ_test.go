@@ -116,6 +116,9 @@ var differTests = []struct {
 				Mode: Updated,
 				Src:  &Document{ID: "4", Source: map[string]interface{}{"Name": "Four", "Value": 3}},
 				Dst:  &Document{ID: "4", Source: map[string]interface{}{"Name": "Four", "Value": 4}},
+				Changes: []Change{
+					{Path: Path{"Value"}, Kind: Modified, Old: 3, New: 4},
+				},
 			},
 			{
 				Mode: Deleted,
@@ -158,7 +161,7 @@ var differTests = []struct {
 			},
 		},
 	},
-	// #6
+	// #6 Destination is not sorted by ID, e.g. when sorted numerically
 	{
 		Srcs: []*Document{
 			{ID: "239473748", Source: map[string]interface{}{"Name": "Same Document"}},
@@ -168,7 +171,9 @@ var differTests = []struct {
 			{ID: "34", Source: map[string]interface{}{"Name": "New Document"}},
 			{ID: "32", Source: map[string]interface{}{"Name": "New Document 2"}},
 		},
-		Errs: nil,
+		Errs: []error{
+			SortOrderError{Side: "destination", Prev: "34", ID: "32"},
+		},
 		Diffs: []Diff{
 			{
 				Mode: Unchanged,
@@ -180,10 +185,155 @@ var differTests = []struct {
 				Src:  nil,
 				Dst:  &Document{ID: "34", Source: map[string]interface{}{"Name": "New Document"}},
 			},
+		},
+	},
+	// #7 Source has more documents after the destination ends
+	{
+		Srcs: []*Document{
+			{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+			{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+			{ID: "3", Source: map[string]interface{}{"Name": "Three"}},
+		},
+		Dsts: []*Document{
+			{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+		},
+		Errs: nil,
+		Diffs: []Diff{
+			{
+				Mode: Unchanged,
+				Src:  &Document{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+				Dst:  &Document{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+			},
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+				Dst:  nil,
+			},
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "3", Source: map[string]interface{}{"Name": "Three"}},
+				Dst:  nil,
+			},
+		},
+	},
+	// #8 Source is sorted in descending order
+	{
+		Srcs: []*Document{
+			{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+			{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+		},
+		Dsts: []*Document{
+			{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+			{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+		},
+		Errs: []error{
+			SortOrderError{Side: "source", Prev: "2", ID: "1"},
+		},
+		Diffs: []Diff{
+			{
+				Mode: Created,
+				Src:  nil,
+				Dst:  &Document{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+			},
+			{
+				Mode: Unchanged,
+				Src:  &Document{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+				Dst:  &Document{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+			},
+		},
+	},
+	// #9 Source contains an ID twice, e.g. with a replacement ID
+	{
+		Srcs: []*Document{
+			{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+			{ID: "1", Source: map[string]interface{}{"Name": "One again"}},
+		},
+		Dsts: nil,
+		Errs: []error{
+			SortOrderError{Side: "source", Prev: "1", ID: "1", Duplicate: true},
+		},
+		Diffs: []Diff{
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+				Dst:  nil,
+			},
+		},
+	},
+	// #10 Destination is not sorted after the source ends
+	{
+		Srcs: nil,
+		Dsts: []*Document{
+			{ID: "b", Source: map[string]interface{}{"Name": "B"}},
+			{ID: "a", Source: map[string]interface{}{"Name": "A"}},
+		},
+		Errs: []error{
+			SortOrderError{Side: "destination", Prev: "b", ID: "a"},
+		},
+		Diffs: []Diff{
 			{
 				Mode: Created,
 				Src:  nil,
-				Dst:  &Document{ID: "32", Source: map[string]interface{}{"Name": "New Document 2"}},
+				Dst:  &Document{ID: "b", Source: map[string]interface{}{"Name": "B"}},
+			},
+		},
+	},
+	// #11 Documents are sorted by key, e.g. numeric IDs
+	{
+		Srcs: []*Document{
+			{ID: "9", Key: "1", Source: map[string]interface{}{"Name": "Nine"}},
+			{ID: "10", Key: "2", Source: map[string]interface{}{"Name": "Ten"}},
+		},
+		Dsts: []*Document{
+			{ID: "10", Key: "2", Source: map[string]interface{}{"Name": "Ten"}},
+		},
+		Errs: nil,
+		Diffs: []Diff{
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "9", Key: "1", Source: map[string]interface{}{"Name": "Nine"}},
+				Dst:  nil,
+			},
+			{
+				Mode: Unchanged,
+				Src:  &Document{ID: "10", Key: "2", Source: map[string]interface{}{"Name": "Ten"}},
+				Dst:  &Document{ID: "10", Key: "2", Source: map[string]interface{}{"Name": "Ten"}},
+			},
+		},
+	},
+	// #12 The same ID in different indices is out of order, not a duplicate
+	{
+		Srcs: []*Document{
+			{ID: "1", Index: "b", Key: "b\x001", Source: map[string]interface{}{"Name": "B"}},
+			{ID: "1", Index: "a", Key: "a\x001", Source: map[string]interface{}{"Name": "A"}},
+		},
+		Dsts: nil,
+		Errs: []error{
+			SortOrderError{Side: "source", Prev: "b/1", ID: "a/1"},
+		},
+		Diffs: []Diff{
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "1", Index: "b", Key: "b\x001", Source: map[string]interface{}{"Name": "B"}},
+				Dst:  nil,
+			},
+		},
+	},
+	// #13 Different IDs with the same key are duplicates
+	{
+		Srcs: []*Document{
+			{ID: "1", Key: "k", Source: map[string]interface{}{"Name": "One"}},
+			{ID: "1.0", Key: "k", Source: map[string]interface{}{"Name": "One again"}},
+		},
+		Dsts: nil,
+		Errs: []error{
+			SortOrderError{Side: "source", Prev: "1", ID: "1.0", Duplicate: true},
+		},
+		Diffs: []Diff{
+			{
+				Mode: Deleted,
+				Src:  &Document{ID: "1", Key: "k", Source: map[string]interface{}{"Name": "One"}},
+				Dst:  nil,
 			},
 		},
 	},
@@ -264,6 +414,9 @@ func TestDiffer(t *testing.T) {
 			if want, have := tt.Diffs[k].Dst, diffs[k].Dst; !cmp.Equal(want, have) {
 				t.Fatalf("#%d: Diffs[%d].Dst: %v", i, k, cmp.Diff(want, have))
 			}
+			if want, have := tt.Diffs[k].Changes, diffs[k].Changes; !cmp.Equal(want, have) {
+				t.Fatalf("#%d: Diffs[%d].Changes: %v", i, k, cmp.Diff(want, have))
+			}
 			switch diffs[k].Mode {
 			case Unchanged:
 			case Created:
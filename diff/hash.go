@@ -0,0 +1,352 @@
+package diff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultMaxInMemory is the default number of documents without
+	// a match that HashDiffer keeps in memory.
+	defaultMaxInMemory = 100000
+
+	// spillPartitions is the number of partitions that HashDiffer
+	// splits source and destination into when spilling to disk.
+	spillPartitions = 64
+)
+
+// HashDiffer compares the documents in the source index to those in the
+// destination index, like Differ. Unlike Differ, it doesn't require the
+// documents to be sorted by ID, e.g. when iterating by another sort field
+// or in descending order.
+//
+// HashDiffer reads source and destination concurrently and keeps the
+// documents that have no match on the other side yet in hash tables. If
+// they hold more documents than WithMaxInMemory allows, HashDiffer
+// partitions the remaining documents of both sides by ID into temporary
+// files (see WithTempDir), then compares the partitions one by one. The
+// memory is then bounded by the largest partition rather than by
+// WithMaxInMemory: all destination documents of a partition are loaded.
+//
+// The outcomes are returned in no particular order, except that Deleted
+// and Created documents that have no match come last. Keys must be unique
+// on both sides: HashDiffer keeps the keys of all documents it has read to
+// detect duplicates, and stops with a SortOrderError like Differ does.
+func HashDiffer(
+	ctx context.Context,
+	srcCh <-chan *Document,
+	dstCh <-chan *Document,
+	opts ...Option,
+) (<-chan Diff, <-chan error) {
+	o := newOptions(opts...)
+	diffCh := make(chan Diff)
+	errCh := make(chan error)
+
+	go func() {
+		defer func() {
+			close(diffCh)
+			close(errCh)
+		}()
+
+		j := &hashJoin{o: o, diffCh: diffCh}
+		if err := j.run(ctx, srcCh, dstCh); err != nil {
+			errCh <- err
+		}
+	}()
+
+	return diffCh, errCh
+}
+
+// hashJoin implements HashDiffer.
+type hashJoin struct {
+	o      *options
+	diffCh chan<- Diff
+
+	src, dst           map[string]*Document
+	srcSpill, dstSpill *spill
+
+	// srcSeen and dstSeen map the keys of all documents read from
+	// either side to their names, to detect duplicates.
+	srcSeen, dstSeen map[string]string
+}
+
+// run joins src and dst, in memory as long as possible.
+func (j *hashJoin) run(ctx context.Context, srcCh, dstCh <-chan *Document) error {
+	j.src = make(map[string]*Document)
+	j.dst = make(map[string]*Document)
+	j.srcSeen = make(map[string]string)
+	j.dstSeen = make(map[string]string)
+	defer func() {
+		if j.srcSpill != nil {
+			j.srcSpill.remove()
+		}
+		if j.dstSpill != nil {
+			j.dstSpill.remove()
+		}
+	}()
+
+	for srcCh != nil || dstCh != nil {
+		var err error
+		select {
+		case doc, ok := <-srcCh:
+			if !ok {
+				srcCh = nil
+				continue
+			}
+			err = j.add(ctx, doc, true)
+		case doc, ok := <-dstCh:
+			if !ok {
+				dstCh = nil
+				continue
+			}
+			err = j.add(ctx, doc, false)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if j.srcSpill == nil {
+		if err := j.unmatched(ctx, j.src, Deleted); err != nil {
+			return err
+		}
+		return j.unmatched(ctx, j.dst, Created)
+	}
+
+	// Join the spilled documents partition by partition
+	if err := j.srcSpill.flush(); err != nil {
+		return err
+	}
+	if err := j.dstSpill.flush(); err != nil {
+		return err
+	}
+	for i := 0; i < spillPartitions; i++ {
+		table := make(map[string]*Document)
+		err := j.dstSpill.read(i, func(doc *Document) error {
+			table[doc.SortKey()] = doc
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := j.srcSpill.read(i, func(doc *Document) error {
+			return j.probe(ctx, table, doc)
+		}); err != nil {
+			return err
+		}
+		if err := j.unmatched(ctx, table, Created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add compares doc with the document of the same key on the other side,
+// if that has been read already. Otherwise it keeps doc in the hash table
+// of its side, spilling the hash tables if they get too large. It returns
+// a SortOrderError if a document with the same key has been read before
+// on the same side, even if that has been matched already.
+func (j *hashJoin) add(ctx context.Context, doc *Document, isSrc bool) error {
+	seen, side := j.srcSeen, "source"
+	if !isSrc {
+		seen, side = j.dstSeen, "destination"
+	}
+	key := doc.SortKey()
+	if prev, found := seen[key]; found {
+		return SortOrderError{Side: side, Prev: prev, ID: doc.Name(), Duplicate: true}
+	}
+	seen[key] = doc.Name()
+
+	if j.srcSpill != nil {
+		if isSrc {
+			return j.srcSpill.write(doc)
+		}
+		return j.dstSpill.write(doc)
+	}
+
+	own, other := j.src, j.dst
+	if !isSrc {
+		own, other = j.dst, j.src
+	}
+	if match, found := other[key]; found {
+		delete(other, key)
+		if isSrc {
+			return j.compare(ctx, doc, match)
+		}
+		return j.compare(ctx, match, doc)
+	}
+	own[key] = doc
+	if len(j.src)+len(j.dst) <= j.o.maxInMemory {
+		return nil
+	}
+
+	var err error
+	if j.srcSpill, err = newSpill(j.o.tempDir, "src"); err != nil {
+		return err
+	}
+	if j.dstSpill, err = newSpill(j.o.tempDir, "dst"); err != nil {
+		return err
+	}
+	for _, doc := range j.src {
+		if err := j.srcSpill.write(doc); err != nil {
+			return err
+		}
+	}
+	for _, doc := range j.dst {
+		if err := j.dstSpill.write(doc); err != nil {
+			return err
+		}
+	}
+	j.src, j.dst = nil, nil
+	return nil
+}
+
+// probe compares doc from the source with the document of the same ID in
+// table, and removes that from table. If there is none, doc is Deleted.
+func (j *hashJoin) probe(ctx context.Context, table map[string]*Document, doc *Document) error {
+	dst, found := table[doc.SortKey()]
+	if !found {
+		return j.send(ctx, Diff{Mode: Deleted, Src: doc})
+	}
+	delete(table, doc.SortKey())
+	return j.compare(ctx, doc, dst)
+}
+
+// compare returns the outcome of comparing src and dst.
+func (j *hashJoin) compare(ctx context.Context, src, dst *Document) error {
+	if changes := j.o.compare(src.Source, dst.Source); len(changes) > 0 {
+		return j.send(ctx, Diff{Mode: Updated, Src: src, Dst: dst, Changes: changes})
+	}
+	return j.send(ctx, Diff{Mode: Unchanged, Src: src, Dst: dst})
+}
+
+// unmatched returns the documents in table with the given mode, i.e.
+// Deleted for documents of the source and Created for documents of the
+// destination, sorted by key.
+func (j *hashJoin) unmatched(ctx context.Context, table map[string]*Document, mode Mode) error {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		d := Diff{Mode: mode, Src: table[key]}
+		if mode == Created {
+			d = Diff{Mode: mode, Dst: table[key]}
+		}
+		if err := j.send(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send returns d, unless the context is canceled.
+func (j *hashJoin) send(ctx context.Context, d Diff) error {
+	select {
+	case j.diffCh <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spill partitions documents by ID into temporary NDJSON files.
+type spill struct {
+	dir   string
+	files []*os.File
+	bufs  []*bufio.Writer
+	encs  []*json.Encoder
+}
+
+// newSpill creates the partition files in a new directory below dir.
+func newSpill(dir, name string) (*spill, error) {
+	tmpdir, err := os.MkdirTemp(dir, "esdiff-"+name+"-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create directory for spilling")
+	}
+	s := &spill{dir: tmpdir}
+	for i := 0; i < spillPartitions; i++ {
+		f, err := os.Create(filepath.Join(tmpdir, fmt.Sprintf("%03d.ndjson", i)))
+		if err != nil {
+			s.remove()
+			return nil, errors.Wrap(err, "unable to create file for spilling")
+		}
+		bw := bufio.NewWriter(f)
+		s.files = append(s.files, f)
+		s.bufs = append(s.bufs, bw)
+		s.encs = append(s.encs, json.NewEncoder(bw))
+	}
+	return s, nil
+}
+
+// partition returns the partition of a document key.
+func partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % spillPartitions)
+}
+
+// spilledDocument is the format of a document in a partition file. It
+// keeps the fields of Document that are not serialized.
+type spilledDocument struct {
+	Key        string    `json:"key,omitempty"`
+	OriginalID string    `json:"original_id,omitempty"`
+	Doc        *Document `json:"doc"`
+}
+
+// write adds doc to its partition.
+func (s *spill) write(doc *Document) error {
+	return s.encs[partition(doc.SortKey())].Encode(spilledDocument{Key: doc.Key, OriginalID: doc.OriginalID, Doc: doc})
+}
+
+// flush writes the buffered documents of all partitions to disk.
+func (s *spill) flush() error {
+	for _, bw := range s.bufs {
+		if err := bw.Flush(); err != nil {
+			return errors.Wrap(err, "unable to write spilled documents")
+		}
+	}
+	return nil
+}
+
+// read calls fn for every document in partition i.
+func (s *spill) read(i int, fn func(*Document) error) error {
+	f := s.files[i]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var sd spilledDocument
+		if err := dec.Decode(&sd); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "unable to read spilled documents")
+		}
+		sd.Doc.Key = sd.Key
+		sd.Doc.OriginalID = sd.OriginalID
+		if err := fn(sd.Doc); err != nil {
+			return err
+		}
+	}
+}
+
+// remove closes and deletes the partition files.
+func (s *spill) remove() {
+	for _, f := range s.files {
+		f.Close()
+	}
+	os.RemoveAll(s.dir)
+}
@@ -0,0 +1,216 @@
+package diff
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// runHashDiffer runs HashDiffer with srcs and dsts and returns the diffs,
+// sorted by ID, and the errors.
+func runHashDiffer(t *testing.T, srcs, dsts []*Document, opts ...Option) ([]Diff, []error) {
+	t.Helper()
+	ctx := context.Background()
+	done := make(chan struct{})
+	var errs []error
+	var diffs []Diff
+
+	srcCh := make(chan *Document)
+	go func() {
+		defer close(srcCh)
+		for _, doc := range srcs {
+			srcCh <- doc
+		}
+	}()
+	dstCh := make(chan *Document)
+	go func() {
+		defer close(dstCh)
+		for _, doc := range dsts {
+			dstCh <- doc
+		}
+	}()
+
+	go func() {
+		defer close(done)
+		diffCh, errCh := HashDiffer(ctx, srcCh, dstCh, opts...)
+		for diffCh != nil || errCh != nil {
+			select {
+			case d, ok := <-diffCh:
+				if !ok {
+					diffCh = nil
+					continue
+				}
+				diffs = append(diffs, d)
+			case err, ok := <-errCh:
+				if !ok {
+					errCh = nil
+					continue
+				}
+				errs = append(errs, err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	sortDiffs(diffs)
+	return diffs, errs
+}
+
+// sortDiffs sorts diffs by the ID of their documents.
+func sortDiffs(diffs []Diff) {
+	id := func(d Diff) string {
+		if d.Src != nil {
+			return d.Src.ID
+		}
+		return d.Dst.ID
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return id(diffs[i]) < id(diffs[j])
+	})
+}
+
+func TestHashDiffer(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	for i, tt := range differTests {
+		if len(tt.Errs) > 0 {
+			// HashDiffer doesn't depend on the sort order
+			continue
+		}
+		diffs, errs := runHashDiffer(t, tt.Srcs, tt.Dsts)
+		if len(errs) > 0 {
+			t.Fatalf("#%d: %v", i, errs)
+		}
+		want := append([]Diff(nil), tt.Diffs...)
+		sortDiffs(want)
+		if !cmp.Equal(want, diffs) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, diffs))
+		}
+	}
+}
+
+func TestHashDifferUnsorted(t *testing.T) {
+	srcs := []*Document{
+		{ID: "c", Source: map[string]interface{}{"Name": "C", "Price": 3.5}},
+		{ID: "a", Source: map[string]interface{}{"Name": "A", "Price": 1.0}},
+		{ID: "e", Source: map[string]interface{}{"Name": "E"}},
+		{ID: "b", Source: map[string]interface{}{"Name": "B"}},
+	}
+	dsts := []*Document{
+		{ID: "d", Source: map[string]interface{}{"Name": "D"}},
+		{ID: "b", Source: map[string]interface{}{"Name": "B"}},
+		{ID: "a", Source: map[string]interface{}{"Name": "A", "Price": 1.5}},
+		{ID: "c", Source: map[string]interface{}{"Name": "C", "Price": 3.5}},
+	}
+	want := []Diff{
+		{Mode: Updated, Src: srcs[1], Dst: dsts[2], Changes: []Change{{Path: Path{"Price"}, Kind: Modified, Old: 1.0, New: 1.5}}},
+		{Mode: Unchanged, Src: srcs[3], Dst: dsts[1]},
+		{Mode: Unchanged, Src: srcs[0], Dst: dsts[3]},
+		{Mode: Created, Dst: dsts[0]},
+		{Mode: Deleted, Src: srcs[2]},
+	}
+
+	tests := []struct {
+		Name string
+		Opts []Option
+	}{
+		{Name: "in memory"},
+		{Name: "spill", Opts: []Option{WithMaxInMemory(2)}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		opts := append(tt.Opts, WithTempDir(dir))
+		diffs, errs := runHashDiffer(t, srcs, dsts, opts...)
+		if len(errs) > 0 {
+			t.Fatalf("%s: %v", tt.Name, errs)
+		}
+		if !cmp.Equal(want, diffs) {
+			t.Fatalf("%s: %v", tt.Name, cmp.Diff(want, diffs))
+		}
+		// Temporary files must be removed
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) > 0 {
+			t.Fatalf("%s: expected no temporary files, got %d", tt.Name, len(entries))
+		}
+	}
+}
+
+func TestHashDifferDuplicates(t *testing.T) {
+	dsts := []*Document{
+		{ID: "1", Source: map[string]interface{}{"Name": "One"}},
+		{ID: "2", Source: map[string]interface{}{"Name": "Two"}},
+		{ID: "1", Source: map[string]interface{}{"Name": "One again"}},
+	}
+	for _, opts := range [][]Option{nil, {WithMaxInMemory(1), WithTempDir(t.TempDir())}} {
+		// Duplicates in the destination
+		_, errs := runHashDiffer(t, nil, dsts, opts...)
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), `duplicate document "1" in destination`) {
+			t.Fatalf("expected error for duplicate document, got %v", errs)
+		}
+
+		// Duplicates in the source
+		_, errs = runHashDiffer(t, dsts, nil, opts...)
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), `duplicate document "1" in source`) {
+			t.Fatalf("expected error for duplicate document, got %v", errs)
+		}
+	}
+}
+
+func TestHashDifferMatchedDuplicates(t *testing.T) {
+	// The second document with key 1 follows after the first one has
+	// been matched already
+	srcs := []*Document{{ID: "1", Index: "a", Source: map[string]interface{}{"Name": "One"}}}
+	dsts := []*Document{
+		{ID: "1", Index: "x", Source: map[string]interface{}{"Name": "One"}},
+		{ID: "1", Index: "y", Source: map[string]interface{}{"Name": "One"}},
+	}
+	for _, opts := range [][]Option{nil, {WithMaxInMemory(1), WithTempDir(t.TempDir())}} {
+		_, errs := runHashDiffer(t, srcs, dsts, opts...)
+		want := SortOrderError{Side: "destination", Prev: "x/1", ID: "y/1", Duplicate: true}
+		if len(errs) != 1 || errs[0] != error(want) {
+			t.Fatalf("want error %v, have %v", want, errs)
+		}
+
+		_, errs = runHashDiffer(t, dsts, srcs, opts...)
+		want.Side = "source"
+		if len(errs) != 1 || errs[0] != error(want) {
+			t.Fatalf("want error %v, have %v", want, errs)
+		}
+	}
+}
+
+func TestHashDifferSpillKeepsKeyAndOriginalID(t *testing.T) {
+	// Documents with IDs replaced by a numeric field
+	srcs := []*Document{
+		{ID: "10", Key: "k10", OriginalID: "a", Source: map[string]interface{}{"num": 10.0}},
+		{ID: "9", Key: "k09", OriginalID: "b", Source: map[string]interface{}{"num": 9.0}},
+	}
+	dsts := []*Document{
+		{ID: "11", Key: "k11", OriginalID: "c", Source: map[string]interface{}{"num": 11.0}},
+	}
+	diffs, errs := runHashDiffer(t, srcs, dsts, WithMaxInMemory(1), WithTempDir(t.TempDir()))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := []Diff{
+		{Mode: Deleted, Src: srcs[0]},
+		{Mode: Created, Dst: dsts[0]},
+		{Mode: Deleted, Src: srcs[1]},
+	}
+	if !cmp.Equal(want, diffs) {
+		t.Fatal(cmp.Diff(want, diffs))
+	}
+}
@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldDiff is the outcome of comparing the definition of a field in
+// the mappings of source and destination index.
+type FieldDiff struct {
+	// Mode is Created for fields that only exist in the destination,
+	// Deleted for fields that only exist in the source, and Updated
+	// for fields with a different definition.
+	Mode Mode
+	// Field is the name of the field in dot notation, e.g. "user.name"
+	// or "title.keyword" for multi-fields. It is empty for the settings
+	// of the mapping itself, e.g. dynamic or _source.
+	Field string
+	// Src and Dst are the definitions of the field, e.g. its type and
+	// analyzer, without the definitions of its sub-fields.
+	Src map[string]interface{}
+	Dst map[string]interface{}
+	// Changes lists the changes of the definition of Updated fields.
+	Changes []Change
+}
+
+// CompareMappings compares the mappings of source and destination index
+// field by field. Fields matching WithIgnorePaths are skipped. It returns
+// the differences sorted by field name.
+func CompareMappings(src, dst map[string]interface{}, opts ...Option) []FieldDiff {
+	o := newOptions(opts...)
+	// The ignore patterns apply to field names, not to their definitions
+	attrs := *o
+	attrs.ignore = nil
+
+	srcRoot, srcFields := flattenMapping(src)
+	dstRoot, dstFields := flattenMapping(dst)
+
+	var diffs []FieldDiff
+	if changes := attrs.compare(srcRoot, dstRoot); len(changes) > 0 {
+		diffs = append(diffs, FieldDiff{Mode: Updated, Src: srcRoot, Dst: dstRoot, Changes: changes})
+	}
+
+	names := make([]string, 0, len(srcFields)+len(dstFields))
+	for name := range srcFields {
+		names = append(names, name)
+	}
+	for name := range dstFields {
+		if _, found := srcFields[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if o.ignored(Path{name}) {
+			continue
+		}
+		s, inSrc := srcFields[name]
+		d, inDst := dstFields[name]
+		switch {
+		case inSrc && inDst:
+			if changes := attrs.compare(s, d); len(changes) > 0 {
+				diffs = append(diffs, FieldDiff{Mode: Updated, Field: name, Src: s, Dst: d, Changes: changes})
+			}
+		case inSrc:
+			diffs = append(diffs, FieldDiff{Mode: Deleted, Field: name, Src: s})
+		default:
+			diffs = append(diffs, FieldDiff{Mode: Created, Field: name, Dst: d})
+		}
+	}
+	return diffs
+}
+
+// flattenMapping splits a mapping into the settings of the mapping itself,
+// e.g. dynamic, and the normalized definitions of all fields by name.
+func flattenMapping(mapping map[string]interface{}) (map[string]interface{}, map[string]map[string]interface{}) {
+	root := make(map[string]interface{})
+	fields := make(map[string]map[string]interface{})
+	for key, v := range mapping {
+		if key == "properties" {
+			if props, ok := v.(map[string]interface{}); ok {
+				flattenProperties("", props, fields)
+			}
+			continue
+		}
+		root[key] = normalizeMappingValue(v)
+	}
+	return root, fields
+}
+
+// flattenProperties adds the normalized definitions of props and their
+// sub-fields to fields, prefixing their names with prefix.
+func flattenProperties(prefix string, props map[string]interface{}, fields map[string]map[string]interface{}) {
+	for name, v := range props {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		field := prefix + name
+		attrs := make(map[string]interface{}, len(def))
+		for key, value := range def {
+			if key == "properties" || key == "fields" {
+				continue
+			}
+			attrs[key] = normalizeMappingValue(value)
+		}
+		if _, found := attrs["type"]; !found {
+			// Objects have no explicit type
+			if _, found := def["properties"]; found {
+				attrs["type"] = "object"
+			}
+		}
+		fields[field] = attrs
+
+		if sub, ok := def["properties"].(map[string]interface{}); ok {
+			flattenProperties(field+".", sub, fields)
+		}
+		if sub, ok := def["fields"].(map[string]interface{}); ok {
+			flattenProperties(field+".", sub, fields)
+		}
+	}
+}
+
+// normalizeMappingValue normalizes values that Elasticsearch returns
+// differently between versions, e.g. "false" instead of false.
+func normalizeMappingValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		return v
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = normalizeMappingValue(value)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, value := range v {
+			a[i] = normalizeMappingValue(value)
+		}
+		return a
+	default:
+		return v
+	}
+}
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareMappings(t *testing.T) {
+	src := map[string]interface{}{
+		"dynamic": "true",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":     "text",
+				"analyzer": "standard",
+				"fields": map[string]interface{}{
+					"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256.0},
+				},
+			},
+			"user": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id":     map[string]interface{}{"type": "long"},
+					"legacy": map[string]interface{}{"type": "text", "index": "false"},
+				},
+			},
+			"@timestamp": map[string]interface{}{"type": "date"},
+		},
+	}
+	dst := map[string]interface{}{
+		"dynamic": "strict",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type": "text",
+				"fields": map[string]interface{}{
+					"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+					"raw":     map[string]interface{}{"type": "keyword"},
+				},
+			},
+			"user": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"type": "keyword"},
+				},
+			},
+			"@timestamp": map[string]interface{}{"type": "date_nanos"},
+		},
+	}
+
+	want := []FieldDiff{
+		{
+			Mode:    Updated,
+			Src:     map[string]interface{}{"dynamic": true},
+			Dst:     map[string]interface{}{"dynamic": "strict"},
+			Changes: []Change{{Path: Path{"dynamic"}, Kind: Modified, Old: true, New: "strict"}},
+		},
+		{
+			Mode:    Updated,
+			Field:   "name",
+			Src:     map[string]interface{}{"type": "text", "analyzer": "standard"},
+			Dst:     map[string]interface{}{"type": "text"},
+			Changes: []Change{{Path: Path{"analyzer"}, Kind: Removed, Old: "standard"}},
+		},
+		{
+			Mode:  Created,
+			Field: "name.raw",
+			Dst:   map[string]interface{}{"type": "keyword"},
+		},
+		{
+			Mode:    Updated,
+			Field:   "user.id",
+			Src:     map[string]interface{}{"type": "long"},
+			Dst:     map[string]interface{}{"type": "keyword"},
+			Changes: []Change{{Path: Path{"type"}, Kind: Modified, Old: "long", New: "keyword"}},
+		},
+		{
+			Mode:  Deleted,
+			Field: "user.legacy",
+			Src:   map[string]interface{}{"type": "text", "index": false},
+		},
+	}
+	have := CompareMappings(src, dst, WithIgnorePaths("@timestamp"))
+	if !cmp.Equal(want, have) {
+		t.Fatal(cmp.Diff(want, have))
+	}
+
+	if diffs := CompareMappings(src, src); len(diffs) != 0 {
+		t.Fatalf("want no differences, have %v", diffs)
+	}
+}
@@ -0,0 +1,282 @@
+package diff
+
+import (
+	"path"
+	"strings"
+)
+
+// Option configures how Differ and Compare compare documents.
+type Option func(*options)
+
+// options holds the configuration for comparing documents.
+type options struct {
+	ignore    []pathPattern
+	tolerance []toleranceRule
+	coerce    []pathRule
+	dates     []pathRule
+	arrays    []arrayRule
+
+	// maxInMemory and tempDir configure HashDiffer.
+	maxInMemory int
+	tempDir     string
+}
+
+// newOptions applies opts to the default options.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		maxInMemory: defaultMaxInMemory,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithIgnorePaths ignores all fields matching one of the given patterns
+// when comparing documents. Ignored fields are still part of the documents,
+// e.g. when printing them, but changes to them do not turn a document
+// into an Updated one.
+//
+// Patterns are field names in dot notation, e.g. "_meta.version".
+// Array indices are not part of a field name, i.e. "items.sku" matches
+// the "sku" field of all elements in "items". A "*" matches a single
+// segment or part of it, e.g. "*_at", while "**" matches any number of
+// segments, e.g. "**.@timestamp". A pattern also matches all fields
+// below the field it matches.
+func WithIgnorePaths(patterns ...string) Option {
+	return func(o *options) {
+		for _, p := range patterns {
+			if p = strings.TrimSpace(p); p != "" {
+				o.ignore = append(o.ignore, newPathPattern(p))
+			}
+		}
+	}
+}
+
+// WithNumericTolerance treats two numbers as equal if they differ by
+// at most epsilon, e.g. to ignore floating point noise. The tolerance
+// applies to the fields matching one of the given patterns (see
+// WithIgnorePaths), or to all fields if no patterns are given.
+//
+// Notice that numbers are always compared by value, i.e. 1 and 1.0
+// are equal even without a tolerance.
+func WithNumericTolerance(epsilon float64, patterns ...string) Option {
+	return func(o *options) {
+		o.tolerance = append(o.tolerance, toleranceRule{
+			pathRule: newPathRule(patterns),
+			epsilon:  epsilon,
+		})
+	}
+}
+
+// WithCoercion treats a string and a number as equal if the string
+// represents the number, e.g. "42" and 42. Coercion applies to the
+// fields matching one of the given patterns (see WithIgnorePaths),
+// or to all fields if no patterns are given.
+func WithCoercion(patterns ...string) Option {
+	return func(o *options) {
+		o.coerce = append(o.coerce, newPathRule(patterns))
+	}
+}
+
+// WithDateNormalization treats two dates as equal if they represent the
+// same instant in time, even if they are formatted differently, e.g.
+// "2022-01-01T10:00:00Z" and "2022-01-01T11:00:00.000+01:00". Dates are
+// strings in one of the ISO 8601 formats that Elasticsearch accepts by
+// default, or numbers with milliseconds since the epoch. Normalization
+// applies to the fields matching one of the given patterns (see
+// WithIgnorePaths), or to all fields if no patterns are given.
+func WithDateNormalization(patterns ...string) Option {
+	return func(o *options) {
+		o.dates = append(o.dates, newPathRule(patterns))
+	}
+}
+
+// WithUnorderedArrays compares arrays as multisets, i.e. ignoring the
+// order of their elements. It applies to the arrays in the fields
+// matching one of the given patterns (see WithIgnorePaths), or to all
+// arrays if no patterns are given.
+//
+// If unordered arrays differ, the changes remove the elements without
+// an equal element in the other array, and add the new elements to the
+// end of the array.
+func WithUnorderedArrays(patterns ...string) Option {
+	return func(o *options) {
+		o.arrays = append(o.arrays, arrayRule{pathRule: newPathRule(patterns)})
+	}
+}
+
+// WithArrayKey compares arrays of objects by matching their elements by
+// the value of key, which may be in dot notation, and ignoring their
+// order. Matched elements are compared field by field. It applies to the
+// arrays in the fields matching one of the given patterns (see
+// WithIgnorePaths), or to all arrays if no patterns are given.
+//
+// Arrays with elements that have no key or a duplicate key are compared
+// as in WithUnorderedArrays.
+func WithArrayKey(key string, patterns ...string) Option {
+	return func(o *options) {
+		o.arrays = append(o.arrays, arrayRule{pathRule: newPathRule(patterns), key: key})
+	}
+}
+
+// WithMaxInMemory sets the number of documents without a match on the
+// other side that HashDiffer keeps in memory. If there are more, HashDiffer
+// spills source and destination to temporary files. The default is 100000
+// documents.
+func WithMaxInMemory(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxInMemory = n
+		}
+	}
+}
+
+// WithTempDir sets the directory for the temporary files of HashDiffer.
+// The default is the directory returned by os.TempDir.
+func WithTempDir(dir string) Option {
+	return func(o *options) {
+		o.tempDir = dir
+	}
+}
+
+// ignored returns true if the field at path should be ignored.
+func (o *options) ignored(path Path) bool {
+	if len(o.ignore) == 0 {
+		return false
+	}
+	field := splitField(path)
+	for _, p := range o.ignore {
+		if p.match(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// epsilon returns the numeric tolerance for the field, i.e. the
+// tolerance of the first rule that applies.
+func (o *options) epsilon(field []string) float64 {
+	for _, r := range o.tolerance {
+		if r.applies(field) {
+			return r.epsilon
+		}
+	}
+	return 0
+}
+
+// coerces returns true if strings and numbers are coerced for the field.
+func (o *options) coerces(field []string) bool {
+	return anyApplies(o.coerce, field)
+}
+
+// normalizesDates returns true if dates are normalized for the field.
+func (o *options) normalizesDates(field []string) bool {
+	return anyApplies(o.dates, field)
+}
+
+// unorderedArray returns whether the array at path is unordered and,
+// if so, the key to match its elements by. Rules with a key take
+// precedence.
+func (o *options) unorderedArray(path Path) (unordered bool, key string) {
+	if len(o.arrays) == 0 {
+		return false, ""
+	}
+	field := splitField(path)
+	for _, r := range o.arrays {
+		if r.applies(field) {
+			if r.key != "" {
+				return true, r.key
+			}
+			unordered = true
+		}
+	}
+	return unordered, ""
+}
+
+// splitField returns the segments of the field that path refers to.
+func splitField(path Path) []string {
+	return strings.Split(path.Field(), ".")
+}
+
+// pathRule is a comparison rule that applies to all fields matching
+// one of its patterns, or to all fields if it has no patterns.
+type pathRule struct {
+	patterns []pathPattern
+}
+
+// newPathRule creates a pathRule from patterns in dot notation.
+func newPathRule(patterns []string) pathRule {
+	var r pathRule
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			r.patterns = append(r.patterns, newPathPattern(p))
+		}
+	}
+	return r
+}
+
+// applies returns true if the rule applies to field.
+func (r pathRule) applies(field []string) bool {
+	if len(r.patterns) == 0 {
+		return true
+	}
+	for _, p := range r.patterns {
+		if p.match(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyApplies returns true if one of rules applies to field.
+func anyApplies(rules []pathRule, field []string) bool {
+	for _, r := range rules {
+		if r.applies(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// toleranceRule is a numeric tolerance for a set of fields.
+type toleranceRule struct {
+	pathRule
+	epsilon float64
+}
+
+// arrayRule compares arrays in a set of fields regardless of their order.
+type arrayRule struct {
+	pathRule
+	key string
+}
+
+// pathPattern is a pattern for field names, split into segments.
+type pathPattern []string
+
+// newPathPattern parses a pattern in dot notation.
+func newPathPattern(pattern string) pathPattern {
+	return pathPattern(strings.Split(pattern, "."))
+}
+
+// match returns true if the pattern matches field or one of its parents.
+func (p pathPattern) match(field []string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	if p[0] == "**" {
+		for i := 0; i <= len(field); i++ {
+			if p[1:].match(field[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(field) == 0 {
+		return false
+	}
+	if ok, err := path.Match(p[0], field[0]); err != nil || !ok {
+		return false
+	}
+	return p[1:].match(field[1:])
+}
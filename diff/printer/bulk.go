@@ -0,0 +1,62 @@
+package printer
+
+import (
+	"io"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+)
+
+// BulkPrinter prints diffs as the NDJSON body of a request to the Bulk API
+// of Elasticsearch. Sending it to the destination index makes it match
+// the source, e.g. with
+//
+//	curl -H 'Content-Type: application/x-ndjson' -XPOST 'http://localhost:9200/index01/_bulk' --data-binary @bulk.ndjson
+//
+// Elasticsearch 5.x and 6.x need the type in the URL, as the actions have
+// none, e.g. http://localhost:9200/index01/_doc/_bulk.
+type BulkPrinter struct {
+	w        io.Writer
+	keyIndex bool
+	updated  bool
+	created  bool
+	deleted  bool
+}
+
+// NewBulkPrinter creates a new BulkPrinter. Unchanged documents are never
+// printed as they need no action. keyIndex specifies that documents are
+// matched by index and ID, see diff.NewBulkAction.
+func NewBulkPrinter(w io.Writer, keyIndex, updated, created, deleted bool) *BulkPrinter {
+	return &BulkPrinter{
+		w:        w,
+		keyIndex: keyIndex,
+		updated:  updated,
+		created:  created,
+		deleted:  deleted,
+	}
+}
+
+// Print prints the bulk action for a diff: Documents that were deleted
+// or updated are indexed with their source, and documents that were
+// created are deleted.
+func (p *BulkPrinter) Print(d diff.Diff) error {
+	switch d.Mode {
+	case diff.Updated:
+		if !p.updated {
+			return nil
+		}
+	case diff.Created:
+		if !p.created {
+			return nil
+		}
+	case diff.Deleted:
+		if !p.deleted {
+			return nil
+		}
+	}
+	action, ok := diff.NewBulkAction(d, p.keyIndex)
+	if !ok {
+		return nil
+	}
+	return elastic.WriteBulkBody(p.w, []diff.BulkAction{action})
+}
@@ -0,0 +1,78 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+func TestBulkPrinter(t *testing.T) {
+	src := &diff.Document{ID: "1", Source: map[string]interface{}{"name": "One"}}
+	dst := &diff.Document{ID: "1", Source: map[string]interface{}{"name": "Two"}}
+
+	tests := []struct {
+		Diff diff.Diff
+		Want string
+	}{
+		{
+			Diff: diff.Diff{Mode: diff.Unchanged, Src: src, Dst: src},
+			Want: ``,
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Updated, Src: src, Dst: dst},
+			Want: `{"index":{"_id":"1"}}` + "\n" + `{"name":"One"}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Created, Dst: dst},
+			Want: `{"delete":{"_id":"1"}}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Deleted, Src: src},
+			Want: `{"index":{"_id":"1"}}` + "\n" + `{"name":"One"}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Deleted, Src: &diff.Document{ID: "2"}},
+			Want: `{"index":{"_id":"2"}}` + "\n" + `{}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Updated, Src: src, Dst: &diff.Document{ID: "1", Index: "logs-2024.01.01", Source: dst.Source}},
+			Want: `{"index":{"_index":"logs-2024.01.01","_id":"1"}}` + "\n" + `{"name":"One"}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Created, Dst: &diff.Document{ID: "1", Index: "logs-2024.01.01"}},
+			Want: `{"delete":{"_index":"logs-2024.01.01","_id":"1"}}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Updated, Src: &diff.Document{ID: "1", Routing: "r1", Source: src.Source}, Dst: &diff.Document{ID: "1", Routing: "r2", Source: dst.Source}},
+			Want: `{"index":{"_id":"1","routing":"r1"}}` + "\n" + `{"name":"One"}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Created, Dst: &diff.Document{ID: "1", Routing: "r2"}},
+			Want: `{"delete":{"_id":"1","routing":"r2"}}` + "\n",
+		},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		p := NewBulkPrinter(&buf, false, true, true, true)
+		if err := p.Print(tt.Diff); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Want, buf.String(); want != have {
+			t.Fatalf("#%d: want\n%s\nhave\n%s", i, want, have)
+		}
+	}
+}
+
+func TestBulkPrinterFiltersModes(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewBulkPrinter(&buf, false, true, false, true)
+	d := diff.Diff{Mode: diff.Created, Dst: &diff.Document{ID: "1"}}
+	if err := p.Print(d); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 0 {
+		t.Fatalf("expected no output for created documents, got\n%s", buf.String())
+	}
+}
@@ -33,18 +33,19 @@ func NewJSONPrinter(w io.Writer, unchanged, updated, created, deleted bool) *JSO
 // Print prints a diff as JSON.
 func (p *JSONPrinter) Print(d diff.Diff) error {
 	type rowType struct {
-		Mode string      `json:"mode"`
-		ID   string      `json:"_id"`
-		Src  interface{} `json:"src,omitempty"`
-		Dst  interface{} `json:"dst,omitempty"`
-		// Diff interface{} `json:"diff,omitempty"`
+		Mode    string        `json:"mode"`
+		ID      string        `json:"_id"`
+		Src     interface{}   `json:"src,omitempty"`
+		Dst     interface{}   `json:"dst,omitempty"`
+		Changes []diff.Change `json:"changes,omitempty"`
 	}
 
 	ok := false
 
 	row := rowType{
-		Src: d.Src,
-		Dst: d.Dst,
+		Src:     d.Src,
+		Dst:     d.Dst,
+		Changes: d.Changes,
 	}
 
 	switch d.Mode {
@@ -0,0 +1,112 @@
+package printer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+// JSONPatchPrinter prints diffs as JSON Patch documents as specified
+// in RFC 6902, one line per document. Applying the patch to the source
+// of a document turns it into the destination.
+type JSONPatchPrinter struct {
+	w         io.Writer
+	enc       *json.Encoder
+	unchanged bool
+	updated   bool
+	created   bool
+	deleted   bool
+}
+
+// NewJSONPatchPrinter creates a new JSONPatchPrinter.
+func NewJSONPatchPrinter(w io.Writer, unchanged, updated, created, deleted bool) *JSONPatchPrinter {
+	return &JSONPatchPrinter{
+		w:         w,
+		enc:       json.NewEncoder(w),
+		unchanged: unchanged,
+		updated:   updated,
+		created:   created,
+		deleted:   deleted,
+	}
+}
+
+// PatchOperation is a single operation of a JSON Patch document.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes the operation, omitting the value for operations
+// that don't have one. Notice that the value is required for e.g. "add",
+// even if it is null.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	type operation PatchOperation
+	return json.Marshal(operation(op))
+}
+
+// Print prints a diff as a JSON Patch. Created documents are added as
+// a whole, and deleted documents are removed as a whole.
+func (p *JSONPatchPrinter) Print(d diff.Diff) error {
+	type rowType struct {
+		Mode  string           `json:"mode"`
+		ID    string           `json:"_id"`
+		Patch []PatchOperation `json:"patch"`
+	}
+
+	ok := false
+
+	row := rowType{
+		Patch: []PatchOperation{},
+	}
+
+	switch d.Mode {
+	case diff.Unchanged:
+		row.Mode = "unchanged"
+		row.ID = d.Src.ID
+		ok = p.unchanged
+	case diff.Created:
+		row.Mode = "created"
+		row.ID = d.Dst.ID
+		row.Patch = append(row.Patch, PatchOperation{Op: "add", Path: "", Value: d.Dst.Source})
+		ok = p.created
+	case diff.Updated:
+		row.Mode = "updated"
+		row.ID = d.Src.ID
+		row.Patch = append(row.Patch, NewJSONPatch(d.Changes)...)
+		ok = p.updated
+	case diff.Deleted:
+		row.Mode = "deleted"
+		row.ID = d.Src.ID
+		row.Patch = append(row.Patch, PatchOperation{Op: "remove", Path: ""})
+		ok = p.deleted
+	}
+
+	if ok {
+		return p.enc.Encode(row)
+	}
+	return nil
+}
+
+// NewJSONPatch converts field-level changes into JSON Patch operations.
+func NewJSONPatch(changes []diff.Change) []PatchOperation {
+	ops := make([]PatchOperation, 0, len(changes))
+	for _, c := range changes {
+		switch c.Kind {
+		case diff.Added:
+			ops = append(ops, PatchOperation{Op: "add", Path: c.Path.Pointer(), Value: c.New})
+		case diff.Removed:
+			ops = append(ops, PatchOperation{Op: "remove", Path: c.Path.Pointer()})
+		case diff.Modified:
+			ops = append(ops, PatchOperation{Op: "replace", Path: c.Path.Pointer(), Value: c.New})
+		}
+	}
+	return ops
+}
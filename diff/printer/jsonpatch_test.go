@@ -0,0 +1,50 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+func TestJSONPatchPrinter(t *testing.T) {
+	src := &diff.Document{ID: "1", Source: map[string]interface{}{"name": "One", "tags": []interface{}{"a", "b"}, "old": nil}}
+	dst := &diff.Document{ID: "1", Source: map[string]interface{}{"name": "Two", "tags": []interface{}{"a"}, "new": nil}}
+
+	tests := []struct {
+		Diff diff.Diff
+		Want string
+	}{
+		{
+			Diff: diff.Diff{Mode: diff.Unchanged, Src: src, Dst: src},
+			Want: `{"mode":"unchanged","_id":"1","patch":[]}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Updated, Src: src, Dst: dst, Changes: diff.Compare(src.Source, dst.Source)},
+			Want: `{"mode":"updated","_id":"1","patch":[` +
+				`{"op":"replace","path":"/name","value":"Two"},` +
+				`{"op":"add","path":"/new","value":null},` +
+				`{"op":"remove","path":"/old"},` +
+				`{"op":"remove","path":"/tags/1"}]}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Created, Dst: dst},
+			Want: `{"mode":"created","_id":"1","patch":[{"op":"add","path":"","value":{"name":"Two","new":null,"tags":["a"]}}]}` + "\n",
+		},
+		{
+			Diff: diff.Diff{Mode: diff.Deleted, Src: src},
+			Want: `{"mode":"deleted","_id":"1","patch":[{"op":"remove","path":""}]}` + "\n",
+		},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		p := NewJSONPatchPrinter(&buf, true, true, true, true)
+		if err := p.Print(tt.Diff); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Want, buf.String(); want != have {
+			t.Fatalf("#%d: want\n%s\nhave\n%s", i, want, have)
+		}
+	}
+}
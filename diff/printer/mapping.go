@@ -0,0 +1,85 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+// PrintFieldDiff prints the difference of a field in two mappings in a
+// textual form, e.g. "Updated	name	type: "text" => "keyword"".
+func PrintFieldDiff(w io.Writer, d diff.FieldDiff) error {
+	field := d.Field
+	if field == "" {
+		field = "<mapping>"
+	}
+	var err error
+	switch d.Mode {
+	case diff.Created:
+		_, err = fmt.Fprintf(w, "Created\t%s\t%s\n", field, formatValue(d.Dst))
+	case diff.Deleted:
+		_, err = fmt.Fprintf(w, "Deleted\t%s\t%s\n", field, formatValue(d.Src))
+	case diff.Updated:
+		changes := make([]string, len(d.Changes))
+		for i, c := range d.Changes {
+			changes[i] = formatChange(c)
+		}
+		_, err = fmt.Fprintf(w, "Updated\t%s\t%s\n", field, strings.Join(changes, ", "))
+	}
+	return err
+}
+
+// PrintJSONFieldDiff prints the difference of a field in two mappings
+// as a JSON object on a single line.
+func PrintJSONFieldDiff(w io.Writer, d diff.FieldDiff) error {
+	type rowType struct {
+		Mode    string                 `json:"mode"`
+		Field   string                 `json:"field"`
+		Src     map[string]interface{} `json:"src"`
+		Dst     map[string]interface{} `json:"dst"`
+		Changes []diff.Change          `json:"changes,omitempty"`
+	}
+	return json.NewEncoder(w).Encode(rowType{
+		Mode:    strings.ToLower(d.Mode.String()),
+		Field:   d.Field,
+		Src:     d.Src,
+		Dst:     d.Dst,
+		Changes: d.Changes,
+	})
+}
+
+// formatChange formats a change as e.g. `type: "text" => "keyword"`.
+func formatChange(c diff.Change) string {
+	switch c.Kind {
+	case diff.Added:
+		return fmt.Sprintf("%s: (none) => %s", c.Path, formatValue(c.New))
+	case diff.Removed:
+		return fmt.Sprintf("%s: %s => (none)", c.Path, formatValue(c.Old))
+	default:
+		return fmt.Sprintf("%s: %s => %s", c.Path, formatValue(c.Old), formatValue(c.New))
+	}
+}
+
+// formatValue formats v as JSON.
+func formatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// PrintChange prints a change in a textual form, e.g.
+// `settings.index.refresh_interval: "1s" => "30s"`.
+func PrintChange(w io.Writer, c diff.Change) error {
+	_, err := fmt.Fprintln(w, formatChange(c))
+	return err
+}
+
+// PrintJSONChange prints a change as a JSON object on a single line.
+func PrintJSONChange(w io.Writer, c diff.Change) error {
+	return json.NewEncoder(w).Encode(c)
+}
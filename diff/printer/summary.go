@@ -0,0 +1,49 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+// PrintSummary prints the statistics of a diff in a textual form.
+func PrintSummary(w io.Writer, s *diff.Stats) error {
+	_, err := fmt.Fprintf(w,
+		"Unchanged\t%d\nCreated\t%d\nUpdated\t%d\nDeleted\t%d\n"+
+			"Source\t%d docs\nDestination\t%d docs\nElapsed\t%v (%.1f docs/s)\n",
+		s.Unchanged, s.Created, s.Updated, s.Deleted,
+		s.SrcDocs(), s.DstDocs(),
+		s.Elapsed.Round(time.Millisecond), s.Throughput(),
+	)
+	return err
+}
+
+// PrintJSONSummary prints the statistics of a diff as a JSON object
+// on a single line.
+func PrintJSONSummary(w io.Writer, s *diff.Stats) error {
+	type summaryType struct {
+		Unchanged  int64   `json:"unchanged"`
+		Created    int64   `json:"created"`
+		Updated    int64   `json:"updated"`
+		Deleted    int64   `json:"deleted"`
+		Total      int64   `json:"total"`
+		SrcDocs    int64   `json:"src_docs"`
+		DstDocs    int64   `json:"dst_docs"`
+		ElapsedMS  int64   `json:"elapsed_ms"`
+		Throughput float64 `json:"docs_per_sec"`
+	}
+	return json.NewEncoder(w).Encode(summaryType{
+		Unchanged:  s.Unchanged,
+		Created:    s.Created,
+		Updated:    s.Updated,
+		Deleted:    s.Deleted,
+		Total:      s.Total(),
+		SrcDocs:    s.SrcDocs(),
+		DstDocs:    s.DstDocs(),
+		ElapsedMS:  s.Elapsed.Milliseconds(),
+		Throughput: s.Throughput(),
+	})
+}
@@ -0,0 +1,35 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+func TestSummary(t *testing.T) {
+	var s diff.Stats
+	for _, mode := range []diff.Mode{diff.Unchanged, diff.Unchanged, diff.Created, diff.Updated, diff.Deleted, diff.Deleted, diff.Deleted} {
+		s.Add(diff.Diff{Mode: mode})
+	}
+	s.Elapsed = 2 * time.Second
+
+	var buf bytes.Buffer
+	if err := PrintJSONSummary(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"unchanged":2,"created":1,"updated":1,"deleted":3,"total":7,"src_docs":6,"dst_docs":4,"elapsed_ms":2000,"docs_per_sec":5}` + "\n"
+	if have := buf.String(); want != have {
+		t.Fatalf("want\n%s\nhave\n%s", want, have)
+	}
+
+	buf.Reset()
+	if err := PrintSummary(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+	want = "Unchanged\t2\nCreated\t1\nUpdated\t1\nDeleted\t3\nSource\t6 docs\nDestination\t4 docs\nElapsed\t2s (5.0 docs/s)\n"
+	if have := buf.String(); want != have {
+		t.Fatalf("want\n%s\nhave\n%s", want, have)
+	}
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stats summarizes the outcome of comparing the documents in source
+// and destination index.
+type Stats struct {
+	Unchanged int64
+	Created   int64
+	Updated   int64
+	Deleted   int64
+	// Elapsed is the time it took to compare the documents.
+	Elapsed time.Duration
+}
+
+// Add counts the diff d.
+func (s *Stats) Add(d Diff) {
+	switch d.Mode {
+	case Unchanged:
+		s.Unchanged++
+	case Created:
+		s.Created++
+	case Updated:
+		s.Updated++
+	case Deleted:
+		s.Deleted++
+	}
+}
+
+// Total returns the number of documents compared, i.e. the number of
+// distinct IDs found in source and destination.
+func (s *Stats) Total() int64 {
+	return s.Unchanged + s.Created + s.Updated + s.Deleted
+}
+
+// Differences returns the number of documents that are not unchanged.
+func (s *Stats) Differences() int64 {
+	return s.Created + s.Updated + s.Deleted
+}
+
+// DiffRatio returns the ratio of documents that are not unchanged to
+// all documents compared, or 0 if no documents were compared.
+func (s *Stats) DiffRatio() float64 {
+	if total := s.Total(); total > 0 {
+		return float64(s.Differences()) / float64(total)
+	}
+	return 0
+}
+
+// SrcDocs returns the number of documents read from the source.
+func (s *Stats) SrcDocs() int64 {
+	return s.Unchanged + s.Updated + s.Deleted
+}
+
+// DstDocs returns the number of documents read from the destination.
+func (s *Stats) DstDocs() int64 {
+	return s.Unchanged + s.Updated + s.Created
+}
+
+// Throughput returns the number of documents read from source and
+// destination per second.
+func (s *Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.SrcDocs()+s.DstDocs()) / s.Elapsed.Seconds()
+}
+
+// Thresholds limit the differences that are acceptable. Negative values
+// mean that there is no limit.
+type Thresholds struct {
+	MaxCreated int64
+	MaxUpdated int64
+	MaxDeleted int64
+	// MaxDiffRatio is the maximum ratio of created, updated and deleted
+	// documents to all documents, e.g. 0.01 for 1%.
+	MaxDiffRatio float64
+}
+
+// Enabled returns true if at least one of the thresholds has a limit.
+func (t Thresholds) Enabled() bool {
+	return t.MaxCreated >= 0 || t.MaxUpdated >= 0 || t.MaxDeleted >= 0 || t.MaxDiffRatio >= 0
+}
+
+// Exceeded returns a description of every threshold that s exceeds,
+// or nil if s is within all thresholds.
+func (t Thresholds) Exceeded(s *Stats) []string {
+	var exceeded []string
+	if t.MaxCreated >= 0 && s.Created > t.MaxCreated {
+		exceeded = append(exceeded, fmt.Sprintf("%d created documents exceed the maximum of %d", s.Created, t.MaxCreated))
+	}
+	if t.MaxUpdated >= 0 && s.Updated > t.MaxUpdated {
+		exceeded = append(exceeded, fmt.Sprintf("%d updated documents exceed the maximum of %d", s.Updated, t.MaxUpdated))
+	}
+	if t.MaxDeleted >= 0 && s.Deleted > t.MaxDeleted {
+		exceeded = append(exceeded, fmt.Sprintf("%d deleted documents exceed the maximum of %d", s.Deleted, t.MaxDeleted))
+	}
+	if t.MaxDiffRatio >= 0 && s.DiffRatio() > t.MaxDiffRatio {
+		exceeded = append(exceeded, fmt.Sprintf("diff ratio of %g exceeds the maximum of %g", s.DiffRatio(), t.MaxDiffRatio))
+	}
+	return exceeded
+}
@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestThresholds(t *testing.T) {
+	stats := &Stats{Unchanged: 90, Created: 1, Updated: 5, Deleted: 4}
+
+	tests := []struct {
+		Thresholds Thresholds
+		Enabled    bool
+		Exceeded   []string
+	}{
+		// #0
+		{
+			Thresholds: Thresholds{MaxCreated: -1, MaxUpdated: -1, MaxDeleted: -1, MaxDiffRatio: -1},
+			Enabled:    false,
+			Exceeded:   nil,
+		},
+		// #1
+		{
+			Thresholds: Thresholds{MaxCreated: -1, MaxUpdated: 5, MaxDeleted: -1, MaxDiffRatio: 0.1},
+			Enabled:    true,
+			Exceeded:   nil,
+		},
+		// #2
+		{
+			Thresholds: Thresholds{MaxCreated: 0, MaxUpdated: 4, MaxDeleted: -1, MaxDiffRatio: 0.05},
+			Enabled:    true,
+			Exceeded: []string{
+				"1 created documents exceed the maximum of 0",
+				"5 updated documents exceed the maximum of 4",
+				"diff ratio of 0.1 exceeds the maximum of 0.05",
+			},
+		},
+		// #3
+		{
+			Thresholds: Thresholds{},
+			Enabled:    true,
+			Exceeded: []string{
+				"1 created documents exceed the maximum of 0",
+				"5 updated documents exceed the maximum of 0",
+				"4 deleted documents exceed the maximum of 0",
+				"diff ratio of 0.1 exceeds the maximum of 0",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		if want, have := tt.Enabled, tt.Thresholds.Enabled(); want != have {
+			t.Errorf("#%d: Enabled: want %v, have %v", i, want, have)
+		}
+		if want, have := tt.Exceeded, tt.Thresholds.Exceeded(stats); !cmp.Equal(want, have) {
+			t.Errorf("#%d: Exceeded: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// dateLayouts are the date formats recognized when normalizing dates.
+// They resemble the strict_date_optional_time format of Elasticsearch.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// equal returns true if the scalar values src and dst at path are equal,
+// taking the comparison rules for the field into account.
+func (o *options) equal(path Path, src, dst interface{}) bool {
+	var field []string
+	if len(o.tolerance) > 0 || len(o.coerce) > 0 || len(o.dates) > 0 {
+		field = splitField(path)
+	}
+
+	if a, ok := toFloat(src); ok {
+		if b, ok := toFloat(dst); ok {
+			return numbersEqual(a, b, o.epsilon(field))
+		}
+	}
+	if cmp.Equal(src, dst) {
+		return true
+	}
+	if field == nil {
+		return false
+	}
+
+	if o.coerces(field) {
+		a, aok := coerceFloat(src)
+		b, bok := coerceFloat(dst)
+		if aok && bok && numbersEqual(a, b, o.epsilon(field)) {
+			return true
+		}
+	}
+	if o.normalizesDates(field) {
+		a, aok := parseDate(src)
+		b, bok := parseDate(dst)
+		if aok && bok && a.Equal(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// numbersEqual returns true if a and b differ by at most epsilon.
+func numbersEqual(a, b, epsilon float64) bool {
+	return a == b || math.Abs(a-b) <= epsilon
+}
+
+// toFloat returns v as a float64 if v is a number.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// coerceFloat returns v as a float64 if v is either a number or a
+// string that represents a number.
+func coerceFloat(v interface{}) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return toFloat(v)
+}
+
+// parseDate returns v as a time if v is either a string in one of the
+// dateLayouts or a number of milliseconds since the epoch.
+func parseDate(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	if ms, ok := toFloat(v); ok {
+		return time.UnixMilli(int64(ms)), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/file"
+)
+
+// runDump implements the dump command, which writes the documents of
+// an index to an NDJSON file. The documents are written in the same order
+// that Differ expects, so the file can later be used as a source or
+// destination for a diff.
+func runDump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	var (
+		size                    = fs.Int("size", 100, "Batch size")
+		rawSrcQuery             = fs.String("sf", "", `Raw query for filtering the source, e.g. {"term":{"user":"olivere"}}`)
+		srcSort                 = fs.String("ssort", "", `Field to sort the source, e.g. "id" or "-id" (prepend with - for descending)`)
+		srcFilterInclude        = fs.String("include", "", `Raw source filter for including certain fields from the source, e.g. "obj.*"`)
+		srcFilterExclude        = fs.String("exclude", "", `Raw source filter for excluding certain fields from the source, e.g. "hash_value,sub.*"`)
+		replaceWithAnotherField = fs.String("replace-with", "", `Replace the ID with the value of other fields, e.g. "sku", "order.id" or "tenant_id,order_id" (may include _id, _index and _routing)`)
+		matchBy                 = fs.String("match-by", "_id", `Write documents in the order to match them by "_id", or by "_index,_id" (see esdiff -match-by)`)
+		missingKey              = fs.String("missing-key", "error", `What to do with documents without the fields of -replace-with: "error", "skip", or "report" to skip and print them to stderr`)
+		slices                  = fs.Int("slices", 1, `Number of slices to read in parallel`)
+		iterateStrategy         = fs.String("strategy", "auto", `Strategy for iterating over the documents: "scroll", "pit" (point in time), or "auto" to use point in time if supported`)
+	)
+	cfgOpts := configFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Dump usage:\n\n")
+		fmt.Fprintf(os.Stderr, "\t%s dump [flags] <source-url> <file>\n\n", path.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "The file is gzip-compressed if its name ends with .gz.\n\n")
+		fmt.Fprintf(os.Stderr, "Dump flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	strategy, err := elastic.ParseIterateStrategy(*iterateStrategy)
+	if err != nil {
+		fatal(err)
+	}
+
+	keyIndex, err := parseMatchBy(*matchBy)
+	if err != nil {
+		fatal(err)
+	}
+	missingKeyPolicy, err := elastic.ParseMissingKeyPolicy(*missingKey)
+	if err != nil {
+		fatal(err)
+	}
+	var missingKeys int64
+
+	var srcFilterIncludes []string
+	if *srcFilterInclude != "" {
+		srcFilterIncludes = strings.Split(*srcFilterInclude, ",")
+	}
+	var srcFilterExcludes []string
+	if *srcFilterExclude != "" {
+		srcFilterExcludes = strings.Split(*srcFilterExclude, ",")
+	}
+
+	src, err := newClient(fs.Arg(0), cfgOpts(), elastic.WithBatchSize(*size))
+	if err != nil {
+		fatal(err)
+	}
+	srcIterReq := &elastic.IterateRequest{
+		RawQuery:            *rawSrcQuery,
+		SortField:           *srcSort,
+		ReplaceField:        *replaceWithAnotherField,
+		SourceFilterInclude: srcFilterIncludes,
+		SourceFilterExclude: srcFilterExcludes,
+		Strategy:            strategy,
+		Slices:              *slices,
+		KeyIndex:            keyIndex,
+		MissingKey:          missingKeyPolicy,
+		OnMissingKey:        reportMissingKey("source", &missingKeys),
+	}
+
+	filename := fs.Arg(1)
+	if file.IsFileURL(filename) {
+		filename = strings.TrimPrefix(filename, "file://")
+	}
+	if err := dump(context.Background(), src, srcIterReq, filename); err != nil {
+		fatal(err)
+	}
+	if missingKeys > 0 {
+		log.Printf("skipped %d documents without a key", missingKeys)
+	}
+}
+
+// dump writes all documents returned by iterating src to filename.
+// It writes to a temporary file first and only renames it to filename
+// if iteration succeeds.
+//
+// The documents keep their original _id, _index and _routing, even if
+// the request replaces the ID, so reading the file with the same
+// -replace-with and -match-by gets the same keys in the same order.
+func dump(ctx context.Context, src elastic.Client, req *elastic.IterateRequest, filename string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tmpname := filename + ".tmp"
+	f, err := os.Create(tmpname)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpname)
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var zw *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		zw = gzip.NewWriter(bw)
+		w = zw
+	}
+
+	// lineType is the format of a single line in the file, which
+	// is compatible to what e.g. elasticdump writes.
+	type lineType struct {
+		Index   string                 `json:"_index,omitempty"`
+		ID      string                 `json:"_id"`
+		Routing string                 `json:"_routing,omitempty"`
+		Source  map[string]interface{} `json:"_source"`
+	}
+
+	enc := json.NewEncoder(w)
+	docCh, errCh := src.Iterate(ctx, req)
+	for doc := range docCh {
+		id := doc.OriginalID
+		if id == "" {
+			id = doc.ID
+		}
+		if err := enc.Encode(lineType{Index: doc.Index, ID: id, Routing: doc.Routing, Source: doc.Source}); err != nil {
+			return errors.Wrapf(err, "unable to write document %q", id)
+		}
+	}
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpname, filename)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/file"
+)
+
+// sliceClient is an elastic.Client that returns docs, then err.
+type sliceClient struct {
+	docs []*diff.Document
+	err  error
+}
+
+func (c *sliceClient) Iterate(ctx context.Context, req *elastic.IterateRequest) (<-chan *diff.Document, <-chan error) {
+	docCh := make(chan *diff.Document)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(docCh)
+		defer close(errCh)
+		for _, doc := range c.docs {
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+		if c.err != nil {
+			errCh <- c.err
+		}
+	}()
+	return docCh, errCh
+}
+
+// readDump reads the documents of the file at path with req.
+func readDump(t *testing.T, path string, req *elastic.IterateRequest) []*diff.Document {
+	t.Helper()
+	c, err := file.NewClient("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docCh, errCh := c.Iterate(context.Background(), req)
+	var docs []*diff.Document
+	for doc := range docCh {
+		docs = append(docs, doc)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+func TestDump(t *testing.T) {
+	// Documents as returned with -replace-with=num, sorted numerically
+	req := &elastic.IterateRequest{ReplaceField: "num"}
+	var docs []*diff.Document
+	for _, hit := range []struct {
+		ID, Index, Routing, Source string
+	}{
+		{ID: "c", Index: "index01", Routing: "r1", Source: `{"num":9}`},
+		{ID: "a", Index: "index01", Source: `{"num":10}`},
+		{ID: "b", Index: "index02", Source: `{"num":100}`},
+	} {
+		doc := new(diff.Document)
+		if err := json.Unmarshal([]byte(hit.Source), &doc.Source); err != nil {
+			t.Fatal(err)
+		}
+		meta := elastic.DocumentMeta{ID: hit.ID, Index: hit.Index, Routing: hit.Routing, Source: []byte(hit.Source)}
+		if _, err := elastic.SetDocumentID(req, doc, meta); err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, name := range []string{"dump.ndjson", "dump.ndjson.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := dump(context.Background(), &sliceClient{docs: docs}, req, path); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// The file keeps the original _id, _index and _routing
+		type hit struct{ ID, Index, Routing string }
+		var hits []hit
+		for _, doc := range readDump(t, path+"?sort=true", &elastic.IterateRequest{}) {
+			hits = append(hits, hit{ID: doc.ID, Index: doc.Index, Routing: doc.Routing})
+		}
+		want := []hit{
+			{ID: "a", Index: "index01"},
+			{ID: "b", Index: "index02"},
+			{ID: "c", Index: "index01", Routing: "r1"},
+		}
+		if !cmp.Equal(want, hits) {
+			t.Fatalf("%s: %v", name, cmp.Diff(want, hits))
+		}
+
+		// Reading with the same key gets the same IDs in the same order
+		var ids []string
+		for _, doc := range readDump(t, path, req) {
+			ids = append(ids, doc.ID)
+		}
+		if want := []string{"9", "10", "100"}; !cmp.Equal(want, ids) {
+			t.Fatalf("%s: %v", name, cmp.Diff(want, ids))
+		}
+	}
+}
+
+func TestDumpError(t *testing.T) {
+	failure := errors.New("iteration failed")
+	src := &sliceClient{
+		docs: []*diff.Document{{ID: "1", Source: map[string]interface{}{"name": "One"}}},
+		err:  failure,
+	}
+	path := filepath.Join(t.TempDir(), "dump.ndjson.gz")
+	if err := dump(context.Background(), src, &elastic.IterateRequest{}, path); err != failure {
+		t.Fatalf("want error %v, have %v", failure, err)
+	}
+	// Neither the file nor its temporary file must remain
+	for _, name := range []string{path, path + ".tmp"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("want %s not to exist, have %v", name, err)
+		}
+	}
+}
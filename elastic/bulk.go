@@ -0,0 +1,137 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+// BulkClient is implemented by clients that can write to their index.
+type BulkClient interface {
+	Client
+	// Bulk sends the actions to the index in a single request
+	// to the Bulk API.
+	Bulk(context.Context, *BulkRequest) (*BulkResponse, error)
+}
+
+// BulkRequest specifies a request for the Bulk function.
+type BulkRequest struct {
+	Actions []diff.BulkAction
+	// Refresh is the refresh policy, i.e. "true", "false" or "wait_for".
+	// If empty, the cluster default is used.
+	Refresh string
+}
+
+// BulkResponse is the outcome of a Bulk request.
+type BulkResponse struct {
+	// Indexed is the number of documents that were indexed.
+	Indexed int
+	// Deleted is the number of documents that were deleted.
+	Deleted int
+	// NotFound lists the delete actions of documents that didn't exist,
+	// e.g. because they have been deleted in the meantime, or because
+	// their routing is wrong.
+	NotFound []BulkFailure
+	// Failed lists the actions that failed.
+	Failed []BulkFailure
+}
+
+// BulkFailure describes an action of a BulkRequest that failed.
+type BulkFailure struct {
+	Op     diff.BulkOp
+	ID     string
+	Status int
+	Reason string
+}
+
+// ParseRefresh validates a refresh policy, e.g. "true" or "wait_for".
+func ParseRefresh(refresh string) (string, error) {
+	switch strings.ToLower(refresh) {
+	case "":
+		return "", nil
+	case "true", "false", "wait_for":
+		return strings.ToLower(refresh), nil
+	default:
+		return "", errors.Errorf("unknown refresh policy %q", refresh)
+	}
+}
+
+// WriteBulkBody writes the actions in the NDJSON format of the Bulk API
+// to w. Actions without an index are written without index name, so the
+// request needs to be sent to the endpoint of the index. As there is no
+// type either, Elasticsearch 5.x and 6.x need the endpoint of the type,
+// e.g. /index01/_doc/_bulk.
+func WriteBulkBody(w io.Writer, actions []diff.BulkAction) error {
+	type metaType struct {
+		Index   string `json:"_index,omitempty"`
+		ID      string `json:"_id"`
+		Routing string `json:"routing,omitempty"`
+	}
+	enc := json.NewEncoder(w)
+	for _, action := range actions {
+		if err := enc.Encode(map[diff.BulkOp]metaType{action.Op: {Index: action.Index, ID: action.ID, Routing: action.Routing}}); err != nil {
+			return err
+		}
+		if action.Op == diff.BulkIndex {
+			if err := enc.Encode(action.Source); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadBulkResponse decodes the response of the Bulk API from r.
+func ReadBulkResponse(r io.Reader) (*BulkResponse, error) {
+	var body struct {
+		Items []map[diff.BulkOp]BulkResponseItem `json:"items"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "unable to decode bulk response")
+	}
+	res := &BulkResponse{}
+	for _, item := range body.Items {
+		for op, v := range item {
+			res.Add(op, v)
+		}
+	}
+	return res, nil
+}
+
+// BulkResponseItem is the outcome of a single action of a Bulk request.
+type BulkResponseItem struct {
+	ID     string     `json:"_id"`
+	Status int        `json:"status"`
+	Error  *BulkError `json:"error,omitempty"`
+}
+
+// BulkError is the error of a failed action of a Bulk request.
+type BulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// Add adds the outcome of an action to the response. Deleting a document
+// that doesn't exist is not a failure, but is reported in NotFound.
+func (r *BulkResponse) Add(op diff.BulkOp, item BulkResponseItem) {
+	f := BulkFailure{Op: op, ID: item.ID, Status: item.Status}
+	if item.Error != nil {
+		f.Reason = item.Error.Type + ": " + item.Error.Reason
+	}
+	switch {
+	case op == diff.BulkDelete && item.Status < 300:
+		r.Deleted++
+	case op == diff.BulkDelete && item.Status == http.StatusNotFound:
+		r.NotFound = append(r.NotFound, f)
+	case op != diff.BulkDelete && item.Status < 300:
+		r.Indexed++
+	default:
+		r.Failed = append(r.Failed, f)
+	}
+}
@@ -0,0 +1,68 @@
+package elastic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+func TestWriteBulkBody(t *testing.T) {
+	actions := []diff.BulkAction{
+		{Op: diff.BulkIndex, ID: "1", Source: map[string]interface{}{"name": "One"}},
+		{Op: diff.BulkDelete, ID: "2"},
+		{Op: diff.BulkDelete, ID: "3", Index: "logs-2024.01.01", Routing: "r1"},
+	}
+	var buf bytes.Buffer
+	if err := WriteBulkBody(&buf, actions); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"index":{"_id":"1"}}` + "\n" +
+		`{"name":"One"}` + "\n" +
+		`{"delete":{"_id":"2"}}` + "\n" +
+		`{"delete":{"_index":"logs-2024.01.01","_id":"3","routing":"r1"}}` + "\n"
+	if have := buf.String(); want != have {
+		t.Fatalf("want\n%s\nhave\n%s", want, have)
+	}
+}
+
+func TestReadBulkResponse(t *testing.T) {
+	body := `{"took":3,"errors":true,"items":[
+		{"index":{"_id":"1","status":201,"result":"created"}},
+		{"index":{"_id":"2","status":200,"result":"updated"}},
+		{"delete":{"_id":"3","status":200,"result":"deleted"}},
+		{"delete":{"_id":"4","status":404,"result":"not_found"}},
+		{"index":{"_id":"5","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [age]"}}}
+	]}`
+	res, err := ReadBulkResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BulkResponse{
+		Indexed: 2,
+		Deleted: 1,
+		NotFound: []BulkFailure{
+			{Op: diff.BulkDelete, ID: "4", Status: 404},
+		},
+		Failed: []BulkFailure{
+			{Op: diff.BulkIndex, ID: "5", Status: 400, Reason: "mapper_parsing_exception: failed to parse field [age]"},
+		},
+	}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Fatalf("unexpected response (-want +have):\n%s", diff)
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	for _, s := range []string{"", "true", "false", "wait_for", "WAIT_FOR"} {
+		if _, err := ParseRefresh(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+	}
+	if _, err := ParseRefresh("now"); err == nil {
+		t.Error("expected an error for an unknown refresh policy")
+	}
+}
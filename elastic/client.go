@@ -2,11 +2,17 @@ package elastic
 
 import (
 	"context"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/olivere/esdiff/diff"
 )
 
 // Client encapsulates access to an Elasticsearch cluster.
+//
+// The index of a client may also be a comma-separated list of indices,
+// an index pattern like logs-2024.*, or an alias.
 type Client interface {
 	Iterate(context.Context, *IterateRequest) (<-chan *diff.Document, <-chan error)
 }
@@ -18,6 +24,55 @@ type IterateRequest struct {
 	ReplaceField        string
 	SourceFilterInclude []string
 	SourceFilterExclude []string
+	Strategy            IterateStrategy
+	// Slices is the number of slices to read in parallel. Values less
+	// than or equal to 1 read the index sequentially.
+	Slices int
+	// Unordered indicates that the documents may be returned in any
+	// order, e.g. because they are compared with diff.HashDiffer. Unless
+	// there is a SortField, they are then read in index order, which
+	// saves sorting them (see UnorderedSort).
+	Unordered bool
+	// KeyIndex matches documents by their index and ID (or the key of
+	// the ReplaceField), e.g. when iterating over an index pattern with
+	// the same IDs in different indices.
+	KeyIndex bool
+	// MissingKey specifies what to do with documents that don't have
+	// the fields of the ReplaceField key.
+	MissingKey MissingKeyPolicy
+	// OnMissingKey is called for every document without the fields of
+	// the key if MissingKey is MissingKeyReport. It may be called
+	// concurrently when reading slices in parallel.
+	OnMissingKey func(*diff.Document)
+}
+
+// IterateStrategy specifies how Iterate pages through the documents
+// of an index.
+type IterateStrategy string
+
+const (
+	// StrategyAuto picks point in time with search_after if the cluster
+	// supports it, and falls back to the Scroll API otherwise.
+	StrategyAuto IterateStrategy = ""
+	// StrategyScroll uses the Scroll API.
+	StrategyScroll IterateStrategy = "scroll"
+	// StrategyPointInTime uses a point in time (PIT) with search_after.
+	StrategyPointInTime IterateStrategy = "pit"
+)
+
+// ParseIterateStrategy returns the IterateStrategy by its name,
+// e.g. "auto", "scroll" or "pit".
+func ParseIterateStrategy(name string) (IterateStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return StrategyAuto, nil
+	case "scroll":
+		return StrategyScroll, nil
+	case "pit":
+		return StrategyPointInTime, nil
+	default:
+		return StrategyAuto, errors.Errorf("unknown iterate strategy %q", name)
+	}
 }
 
 // ClientWithBatchSize should be implemented by clients that
@@ -2,14 +2,18 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 )
 
 // Config represents an Elasticsearch configuration.
 type Config struct {
-	URL      string
+	URL string
+	// Index is the name of an index, a comma-separated list of indices,
+	// an index pattern like logs-2024.*, or an alias.
 	Index    string
 	Type     string
 	Username string
@@ -20,6 +24,103 @@ type Config struct {
 	Infolog  string
 	Errorlog string
 	Tracelog string
+	// CACert is the path to a PEM file with certificates of the
+	// certificate authorities to trust, in addition to the system ones.
+	CACert string
+	// Cert and Key are the paths to PEM files with the certificate and
+	// private key to authenticate the client via TLS.
+	Cert string
+	Key  string
+	// Insecure disables the verification of the server certificate.
+	Insecure bool
+	// APIKey authenticates with an API key. It is either encoded in
+	// base64, as returned by Elasticsearch, or given as "id:api_key".
+	APIKey string
+	// BearerToken authenticates with a bearer token.
+	BearerToken string
+	// Headers are added to every request.
+	Headers http.Header
+	// AWSSigV4 signs requests with AWS Signature Version 4, e.g. for
+	// Amazon OpenSearch Service. It is implied by AWSRegion.
+	AWSSigV4 bool
+	// AWSRegion is the region to sign requests for. If empty, the region
+	// is read from the environment or the shared AWS config file.
+	AWSRegion string
+	// AWSService is the service to sign requests for, e.g. "es" (the
+	// default) for Amazon OpenSearch Service or "aoss" for Amazon
+	// OpenSearch Serverless.
+	AWSService string
+	// AWSProfile is the profile in the shared AWS credentials and config
+	// files to read credentials and region from, if they are not set in
+	// the environment.
+	AWSProfile string
+}
+
+// Option sets a default for the configuration. Settings in the URL
+// take precedence over options.
+type Option func(*Config)
+
+// WithCACert sets the path to a PEM file with certificates of the
+// certificate authorities to trust.
+func WithCACert(path string) Option {
+	return func(cfg *Config) {
+		cfg.CACert = path
+	}
+}
+
+// WithClientCert sets the paths to PEM files with the certificate and
+// private key to authenticate the client via TLS.
+func WithClientCert(cert, key string) Option {
+	return func(cfg *Config) {
+		cfg.Cert = cert
+		cfg.Key = key
+	}
+}
+
+// WithAPIKey sets the API key to authenticate with. The key may be
+// read from the environment or a file, see Secret.
+func WithAPIKey(apiKey string) Option {
+	return func(cfg *Config) {
+		cfg.APIKey = apiKey
+	}
+}
+
+// WithBearerToken sets the bearer token to authenticate with. The token
+// may be read from the environment or a file, see Secret.
+func WithBearerToken(token string) Option {
+	return func(cfg *Config) {
+		cfg.BearerToken = token
+	}
+}
+
+// WithHeader adds a header to every request. The value may be read
+// from the environment or a file, see Secret.
+func WithHeader(name, value string) Option {
+	return func(cfg *Config) {
+		if cfg.Headers == nil {
+			cfg.Headers = make(http.Header)
+		}
+		cfg.Headers.Add(name, value)
+	}
+}
+
+// WithAWSSigV4 signs requests with AWS Signature Version 4 for the
+// given region, service and profile. Any of them may be empty to use
+// the defaults.
+func WithAWSSigV4(enabled bool, region, service, profile string) Option {
+	return func(cfg *Config) {
+		cfg.AWSSigV4 = enabled
+		cfg.AWSRegion = region
+		cfg.AWSService = service
+		cfg.AWSProfile = profile
+	}
+}
+
+// WithInsecure disables the verification of the server certificate.
+func WithInsecure(insecure bool) Option {
+	return func(cfg *Config) {
+		cfg.Insecure = insecure
+	}
 }
 
 // Parse returns the Elasticsearch configuration by extracting it
@@ -28,14 +129,36 @@ type Config struct {
 // Example:
 //   http://127.0.0.1:9200/index/type?shards=1&replicas=0&sniff=false&tracelog=elastic.trace.log
 //
+// TLS is configured with the cacert, cert, key and insecure parameters,
+// e.g. https://127.0.0.1:9200/index?cacert=/etc/ssl/ca.pem&insecure=false.
+//
+// Besides basic authentication via the user info of the URL, the apikey
+// and bearer parameters authenticate with an API key or bearer token.
+// The header parameter adds a header in the form "Name: Value" to every
+// request, and may be repeated. To keep secrets out of the URL, their
+// values can be read from the environment or a file, see Secret.
+//
+// Requests are signed with AWS Signature Version 4 if aws_sigv4=true or
+// aws_region is given. The aws_service and aws_profile parameters set
+// the service to sign for and the profile to read credentials from.
+//
+// The type is optional, e.g. for Elasticsearch 8.x which no longer
+// supports mapping types.
+//
+// The index may also be a comma-separated list of indices, an index
+// pattern, or an alias, e.g. http://127.0.0.1:9200/logs-2024.*,archive.
+//
 // The code above will return a URL of http://127.0.0.1:9200, an index name
 // of store-blobs, and the related settings from the query string.
-func Parse(elasticURL string) (*Config, error) {
+func Parse(elasticURL string, opts ...Option) (*Config, error) {
 	cfg := &Config{
 		Shards:   1,
 		Replicas: 0,
 		Sniff:    false, // sniffing disabled by default
 	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
 
 	uri, err := url.Parse(elasticURL)
 	if err != nil {
@@ -47,7 +170,9 @@ func Parse(elasticURL string) (*Config, error) {
 	}
 	parts := strings.SplitN(indexAndType, "/", 2)
 	cfg.Index = parts[0]
-	cfg.Type = parts[1]
+	if len(parts) > 1 {
+		cfg.Type = parts[1]
+	}
 	if cfg.Index == "" {
 		return nil, fmt.Errorf("missing index and in elastic parameter %q", elasticURL)
 	}
@@ -77,6 +202,62 @@ func Parse(elasticURL string) (*Config, error) {
 	if s := uri.Query().Get("tracelog"); s != "" {
 		cfg.Tracelog = s
 	}
+	if s := uri.Query().Get("cacert"); s != "" {
+		cfg.CACert = s
+	}
+	if s := uri.Query().Get("cert"); s != "" {
+		cfg.Cert = s
+	}
+	if s := uri.Query().Get("key"); s != "" {
+		cfg.Key = s
+	}
+	if s := uri.Query().Get("insecure"); s != "" {
+		if b, err := strconv.ParseBool(s); err == nil {
+			cfg.Insecure = b
+		}
+	}
+	if s := uri.Query().Get("apikey"); s != "" {
+		cfg.APIKey = s
+	}
+	if s := uri.Query().Get("bearer"); s != "" {
+		cfg.BearerToken = s
+	}
+	if s := uri.Query().Get("aws_sigv4"); s != "" {
+		if b, err := strconv.ParseBool(s); err == nil {
+			cfg.AWSSigV4 = b
+		}
+	}
+	if s := uri.Query().Get("aws_region"); s != "" {
+		cfg.AWSRegion = s
+	}
+	if s := uri.Query().Get("aws_service"); s != "" {
+		cfg.AWSService = s
+	}
+	if s := uri.Query().Get("aws_profile"); s != "" {
+		cfg.AWSProfile = s
+	}
+	for _, s := range uri.Query()["header"] {
+		name, value, err := ParseHeader(s)
+		if err != nil {
+			return nil, err
+		}
+		WithHeader(name, value)(cfg)
+	}
+
+	if cfg.APIKey, err = Secret(cfg.APIKey); err != nil {
+		return nil, err
+	}
+	if cfg.BearerToken, err = Secret(cfg.BearerToken); err != nil {
+		return nil, err
+	}
+	for name, values := range cfg.Headers {
+		for i := range values {
+			if values[i], err = Secret(values[i]); err != nil {
+				return nil, err
+			}
+		}
+		cfg.Headers[name] = values
+	}
 
 	uri.Path = ""
 	uri.RawQuery = ""
@@ -84,3 +265,36 @@ func Parse(elasticURL string) (*Config, error) {
 
 	return cfg, nil
 }
+
+// ParseHeader parses a header in the form "Name: Value".
+func ParseHeader(s string) (name, value string, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return "", "", fmt.Errorf("invalid header %q: expected \"Name: Value\"", s)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+// Secret resolves the value of a secret. If s has the form "env:NAME",
+// the secret is read from the environment variable NAME. If s has the
+// form "file:PATH", the secret is read from the file at PATH, without
+// leading and trailing whitespace. Otherwise, s is the secret itself.
+func Secret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "env:"):
+		name := strings.TrimPrefix(s, "env:")
+		value, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(s, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(s, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return s, nil
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIndex(t *testing.T) {
+	tests := []struct {
+		URL         string
+		Index, Type string
+	}{
+		{URL: "http://localhost:9200/index01", Index: "index01"},
+		{URL: "http://localhost:9200/index01/tweet", Index: "index01", Type: "tweet"},
+		{URL: "http://localhost:9200/logs-2024.*", Index: "logs-2024.*"},
+		{URL: "http://localhost:9200/logs-2024.%2A", Index: "logs-2024.*"},
+		{URL: "http://localhost:9200/logs-2024.01,logs-2024.02/_doc", Index: "logs-2024.01,logs-2024.02", Type: "_doc"},
+		{URL: "http://localhost:9200/logs-current", Index: "logs-current"},
+	}
+	for i, tt := range tests {
+		cfg, err := Parse(tt.URL)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if cfg.Index != tt.Index || cfg.Type != tt.Type {
+			t.Errorf("#%d: want index %q and type %q, have index %q and type %q", i, tt.Index, tt.Type, cfg.Index, cfg.Type)
+		}
+	}
+}
+
+func TestParseAuth(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ESDIFF_TEST_API_KEY", "env-key")
+	defer os.Unsetenv("ESDIFF_TEST_API_KEY")
+
+	cfg, err := Parse(
+		"https://localhost:9200/index01?apikey=env:ESDIFF_TEST_API_KEY&bearer=file:"+tokenFile+"&header=X-Tenant:+acme",
+		WithAPIKey("default-key"),
+		WithHeader("X-Opaque-Id", "esdiff"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "https://localhost:9200", cfg.URL; want != have {
+		t.Errorf("URL: want %q, have %q", want, have)
+	}
+	if want, have := "env-key", cfg.APIKey; want != have {
+		t.Errorf("APIKey: want %q, have %q", want, have)
+	}
+	if want, have := "file-token", cfg.BearerToken; want != have {
+		t.Errorf("BearerToken: want %q, have %q", want, have)
+	}
+	if want, have := "acme", cfg.Headers.Get("X-Tenant"); want != have {
+		t.Errorf("X-Tenant: want %q, have %q", want, have)
+	}
+	if want, have := "esdiff", cfg.Headers.Get("X-Opaque-Id"); want != have {
+		t.Errorf("X-Opaque-Id: want %q, have %q", want, have)
+	}
+
+	if _, err := Parse("https://localhost:9200/index01?apikey=env:ESDIFF_TEST_MISSING"); err == nil {
+		t.Error("want error for missing environment variable, have nil")
+	}
+	if _, err := Parse("https://localhost:9200/index01?header=invalid"); err == nil {
+		t.Error("want error for invalid header, have nil")
+	}
+}
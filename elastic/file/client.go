@@ -0,0 +1,269 @@
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Client implements a client that reads documents from a file with
+// newline-delimited JSON (NDJSON), e.g. created by elasticdump or
+// esdiff dump. Every line is a JSON object with the _id and _source
+// of the document. Gzip-compressed files are detected automatically.
+//
+// The documents are expected to be sorted by ID. If they are not,
+// use the sort=true query parameter to sort them in memory.
+type Client struct {
+	path string
+	sort bool
+}
+
+// NewClient creates a new Client from a URL like
+// file:///path/to/dump.ndjson.gz?sort=true.
+func NewClient(fileURL string) (*Client, error) {
+	uri, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file parameter %q: %v", fileURL, err)
+	}
+	if uri.Scheme != "file" {
+		return nil, fmt.Errorf("invalid scheme in file parameter %q", fileURL)
+	}
+	// Allow relative paths like file://dump.ndjson as well
+	path := uri.Host + uri.Path
+	if path == "" {
+		return nil, fmt.Errorf("missing path in file parameter %q", fileURL)
+	}
+	c := &Client{
+		path: path,
+	}
+	if s := uri.Query().Get("sort"); s != "" {
+		if b, err := strconv.ParseBool(s); err == nil {
+			c.sort = b
+		}
+	}
+	return c, nil
+}
+
+// IsFileURL returns true if the URL refers to a file.
+func IsFileURL(s string) bool {
+	return strings.HasPrefix(s, "file://")
+}
+
+// Iterate iterates over the documents in the file.
+//
+// Queries and sorting by a field are not supported. Source filters
+// are applied to the documents after reading them.
+func (c *Client) Iterate(ctx context.Context, req *elastic.IterateRequest) (<-chan *diff.Document, <-chan error) {
+	docCh := make(chan *diff.Document, 1)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			close(docCh)
+			close(errCh)
+		}()
+
+		if req.RawQuery != "" {
+			errCh <- errors.New("queries are not supported when reading from files")
+			return
+		}
+		if req.SortField != "" {
+			errCh <- errors.New("sorting by a field is not supported when reading from files")
+			return
+		}
+
+		var err error
+		if c.sort {
+			err = c.iterateSorted(ctx, req, docCh)
+		} else {
+			err = c.iterate(ctx, req, docCh)
+		}
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return docCh, errCh
+}
+
+// iterate streams the documents from the file, making sure they are
+// sorted by key unless the request is unordered.
+func (c *Client) iterate(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	var last *diff.Document
+	return c.read(func(doc *diff.Document) error {
+		if !req.Unordered && last != nil && doc.SortKey() < last.SortKey() {
+			return errors.Errorf("documents in %s are not sorted by key (%q follows %q); use the sort=true query parameter to sort them in memory", c.path, doc.Name(), last.Name())
+		}
+		last = doc
+		select {
+		case docCh <- doc:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, req)
+}
+
+// iterateSorted reads all documents from the file, then sorts them
+// by ID (or by key, e.g. for numeric replacement IDs).
+func (c *Client) iterateSorted(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	var docs []*diff.Document
+	err := c.read(func(doc *diff.Document) error {
+		docs = append(docs, doc)
+		return nil
+	}, req)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].SortKey() < docs[j].SortKey()
+	})
+	for _, doc := range docs {
+		select {
+		case docCh <- doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// read reads the documents from the file and passes them to fn.
+func (c *Client) read(fn func(*diff.Document) error, req *elastic.IterateRequest) error {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Detect gzip-compressed files by their magic number
+	var r io.Reader
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read %s", c.path)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	type lineType struct {
+		ID      string          `json:"_id"`
+		Index   string          `json:"_index"`
+		Routing string          `json:"_routing"`
+		Source  json.RawMessage `json:"_source"`
+	}
+
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var l lineType
+		err := dec.Decode(&l)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to read document %d of %s", line, c.path)
+		}
+		var source map[string]interface{}
+		if len(l.Source) > 0 {
+			if err := json.Unmarshal(l.Source, &source); err != nil {
+				return errors.Wrapf(err, "unable to read document %d of %s", line, c.path)
+			}
+		}
+		doc := &diff.Document{
+			Source: filterSource(source, "", req.SourceFilterInclude, req.SourceFilterExclude),
+		}
+		// Replace ID field with some other field from the document?
+		meta := elastic.DocumentMeta{ID: l.ID, Index: l.Index, Routing: l.Routing, Source: l.Source}
+		if ok, err := elastic.SetDocumentID(req, doc, meta); err != nil {
+			return err
+		} else if !ok {
+			// Skipped as it has no key
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// filterSource applies source filtering like Elasticsearch does, i.e.
+// it only keeps fields whose (dotted) path matches one of the includes
+// (if any) and none of the excludes. Patterns may contain wildcards.
+func filterSource(source map[string]interface{}, prefix string, includes, excludes []string) map[string]interface{} {
+	if len(includes)+len(excludes) == 0 {
+		return source
+	}
+	result := make(map[string]interface{})
+	for key, value := range source {
+		path := prefix + key
+		if matchAny(excludes, path) {
+			continue
+		}
+		included := len(includes) == 0 || matchAny(includes, path)
+		if obj, ok := value.(map[string]interface{}); ok {
+			if included {
+				obj = filterSource(obj, path+".", nil, excludes)
+			} else {
+				obj = filterSource(obj, path+".", includes, excludes)
+				if len(obj) == 0 {
+					continue
+				}
+			}
+			result[key] = obj
+			continue
+		}
+		if included {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// matchAny returns true if s matches any of the patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if simpleMatch(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleMatch matches s against a pattern where * matches any
+// sequence of characters.
+func simpleMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
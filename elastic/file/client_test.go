@@ -0,0 +1,163 @@
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+)
+
+func writeFile(t *testing.T, name, data string, compress bool) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if compress {
+		zw := gzip.NewWriter(f)
+		if _, err := zw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func iterate(t *testing.T, url string, req *elastic.IterateRequest) ([]*diff.Document, error) {
+	t.Helper()
+	c, err := NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docCh, errCh := c.Iterate(context.Background(), req)
+	var docs []*diff.Document
+	for doc := range docCh {
+		docs = append(docs, doc)
+	}
+	return docs, <-errCh
+}
+
+const unsortedDump = `{"_index":"index01","_type":"_doc","_id":"3","_score":1,"_source":{"user":"sandrae","message":"Playing the flute, oh boy"}}
+{"_index":"index01","_type":"_doc","_id":"1","_score":1,"_source":{"user":"olivere","message":"Welcome to Golang"}}
+{"_id":"5","_source":{"user":"sandrae","message":"Ran that marathon","meta":{"hash":"abc","lang":"en"}}}
+`
+
+func TestIterate(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		path := writeFile(t, "dump.ndjson", unsortedDump, compress)
+
+		// Unsorted files must fail unless sorted in memory
+		_, err := iterate(t, "file://"+path, &elastic.IterateRequest{})
+		if err == nil {
+			t.Fatalf("compress=%v: expected error for unsorted file", compress)
+		}
+
+		// Unordered requests accept the documents in file order
+		docs, err := iterate(t, "file://"+path, &elastic.IterateRequest{Unordered: true})
+		if err != nil {
+			t.Fatalf("compress=%v: %v", compress, err)
+		}
+		var ids []string
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		if want, have := []string{"3", "1", "5"}, ids; !cmp.Equal(want, have) {
+			t.Fatalf("compress=%v: %v", compress, cmp.Diff(want, have))
+		}
+
+		docs, err = iterate(t, "file://"+path+"?sort=true", &elastic.IterateRequest{
+			SourceFilterExclude: []string{"meta.hash"},
+		})
+		if err != nil {
+			t.Fatalf("compress=%v: %v", compress, err)
+		}
+		want := []*diff.Document{
+			{ID: "1", Index: "index01", Source: map[string]interface{}{"user": "olivere", "message": "Welcome to Golang"}},
+			{ID: "3", Index: "index01", Source: map[string]interface{}{"user": "sandrae", "message": "Playing the flute, oh boy"}},
+			{ID: "5", Source: map[string]interface{}{"user": "sandrae", "message": "Ran that marathon", "meta": map[string]interface{}{"lang": "en"}}},
+		}
+		if !cmp.Equal(want, docs) {
+			t.Fatalf("compress=%v: %v", compress, cmp.Diff(want, docs))
+		}
+	}
+}
+
+func TestIterateKeyIndex(t *testing.T) {
+	const dump = `{"_index":"logs-2024.01.02","_id":"1","_source":{"message":"Second"}}
+{"_index":"logs-2024.01.01","_id":"2","_source":{"message":"First"}}
+{"_index":"logs-2024.01.01","_id":"1","_source":{"message":"First"}}
+`
+	path := writeFile(t, "dump.ndjson", dump, false)
+
+	// The same ID in different indices
+	docs, err := iterate(t, "file://"+path+"?sort=true", &elastic.IterateRequest{KeyIndex: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, doc := range docs {
+		keys = append(keys, doc.Index+"/"+doc.ID)
+	}
+	if want := []string{"logs-2024.01.01/1", "logs-2024.01.01/2", "logs-2024.01.02/1"}; !cmp.Equal(want, keys) {
+		t.Fatal(cmp.Diff(want, keys))
+	}
+}
+
+var filterSourceTests = []struct {
+	Includes, Excludes []string
+	Source, Want       map[string]interface{}
+}{
+	// #0
+	{
+		Source: map[string]interface{}{"a": 1.0, "b": 2.0},
+		Want:   map[string]interface{}{"a": 1.0, "b": 2.0},
+	},
+	// #1
+	{
+		Includes: []string{"a"},
+		Source:   map[string]interface{}{"a": 1.0, "b": 2.0},
+		Want:     map[string]interface{}{"a": 1.0},
+	},
+	// #2
+	{
+		Includes: []string{"obj.*"},
+		Source:   map[string]interface{}{"a": 1.0, "obj": map[string]interface{}{"x": 1.0, "y": 2.0}},
+		Want:     map[string]interface{}{"obj": map[string]interface{}{"x": 1.0, "y": 2.0}},
+	},
+	// #3
+	{
+		Excludes: []string{"hash_value", "sub.*"},
+		Source:   map[string]interface{}{"a": 1.0, "hash_value": "x", "sub": map[string]interface{}{"x": 1.0}},
+		Want:     map[string]interface{}{"a": 1.0, "sub": map[string]interface{}{}},
+	},
+	// #4
+	{
+		Includes: []string{"obj"},
+		Excludes: []string{"*.secret"},
+		Source:   map[string]interface{}{"a": 1.0, "obj": map[string]interface{}{"x": 1.0, "secret": "s"}},
+		Want:     map[string]interface{}{"obj": map[string]interface{}{"x": 1.0}},
+	},
+}
+
+func TestFilterSource(t *testing.T) {
+	for i, tt := range filterSourceTests {
+		have := filterSource(tt.Source, "", tt.Includes, tt.Excludes)
+		if !cmp.Equal(tt.Want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(tt.Want, have))
+		}
+	}
+}
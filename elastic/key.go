@@ -0,0 +1,386 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+const (
+	// keySeparator separates the parts of a composite key. It sorts
+	// before any other character, so that composite keys sort like
+	// Elasticsearch sorts by multiple fields.
+	keySeparator = "\x00"
+
+	// idSeparator separates the parts of a composite ID.
+	idSeparator = "/"
+)
+
+// MissingKeyPolicy specifies what to do with documents that don't have
+// the fields of the key that replaces their ID.
+type MissingKeyPolicy string
+
+const (
+	// MissingKeyError fails with an error.
+	MissingKeyError MissingKeyPolicy = ""
+	// MissingKeySkip skips the document.
+	MissingKeySkip MissingKeyPolicy = "skip"
+	// MissingKeyReport skips the document and passes it to the
+	// OnMissingKey func of the request.
+	MissingKeyReport MissingKeyPolicy = "report"
+)
+
+// ParseMissingKeyPolicy returns the MissingKeyPolicy by its name,
+// e.g. "error", "skip" or "report".
+func ParseMissingKeyPolicy(name string) (MissingKeyPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "error":
+		return MissingKeyError, nil
+	case "skip":
+		return MissingKeySkip, nil
+	case "report":
+		return MissingKeyReport, nil
+	default:
+		return MissingKeyError, errors.Errorf("unknown missing key policy %q", name)
+	}
+}
+
+// Sort is a field to sort the documents by.
+type Sort struct {
+	Field string
+	Asc   bool
+}
+
+// Sorts returns the fields to sort the documents by. It is the SortField
+// of the request, e.g. "-id" for descending order, or the fields of the
+// key if there is no SortField, so that the documents are sorted by the
+// ID that replaces the original one. With KeyIndex, the key starts with
+// _index. _routing is skipped as Elasticsearch can't sort by it. No
+// fields means to sort by ID.
+func Sorts(req *IterateRequest) []Sort {
+	var sorts []Sort
+	if req.SortField != "" {
+		for _, field := range splitFields(req.SortField) {
+			if field[0] == '-' {
+				sorts = append(sorts, Sort{Field: field[1:], Asc: false})
+			} else {
+				sorts = append(sorts, Sort{Field: field, Asc: true})
+			}
+		}
+		return sorts
+	}
+	fields := KeyFields(req)
+	if req.KeyIndex {
+		sorts = append(sorts, Sort{Field: "_index", Asc: true})
+		if len(fields) == 0 {
+			fields = []string{"_id"}
+		}
+	}
+	for _, field := range fields {
+		if field != "_routing" {
+			sorts = append(sorts, Sort{Field: field, Asc: true})
+		}
+	}
+	return sorts
+}
+
+// UnorderedSort returns the field to sort by if the documents of the
+// request may be returned in any order, i.e. if it is Unordered and has
+// no SortField. The Scroll API returns the documents in index order
+// (_doc), which is cheapest, while search_after with a point in time
+// needs a unique sort value (_shard_doc). It returns an empty string if
+// the documents need to be sorted by Sorts.
+func UnorderedSort(req *IterateRequest, pointInTime bool) string {
+	if !req.Unordered || req.SortField != "" {
+		return ""
+	}
+	if pointInTime {
+		return "_shard_doc"
+	}
+	return "_doc"
+}
+
+// NeedsTiebreaker returns true if the documents need to be sorted by _id
+// after the Sorts of the request, so that search_after doesn't skip any
+// documents with the same values in the sort fields.
+func NeedsTiebreaker(req *IterateRequest) bool {
+	sorts := Sorts(req)
+	return len(sorts) > 0 && sorts[len(sorts)-1].Field != "_id"
+}
+
+// KeyFields returns the fields of the key that replaces the ID of the
+// documents, e.g. ["tenant_id", "order.id"] for a ReplaceField of
+// "tenant_id,order.id". No fields means to keep the ID.
+func KeyFields(req *IterateRequest) []string {
+	return splitFields(req.ReplaceField)
+}
+
+// splitFields splits a comma-separated list of fields.
+func splitFields(s string) []string {
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// DocumentMeta is the metadata of a document that can be part of its key.
+type DocumentMeta struct {
+	ID      string
+	Index   string
+	Routing string
+	// Source is the raw source of the document, if available. The fields
+	// of the key are read from it to get numbers exactly, e.g. integer
+	// IDs beyond 2^53 that don't fit into a float64.
+	Source json.RawMessage
+}
+
+// MissingFieldError is returned if a document doesn't have a field of
+// the key that replaces its ID.
+type MissingFieldError struct {
+	ID    string
+	Field string
+}
+
+// Error returns a description of the error.
+func (e MissingFieldError) Error() string {
+	return fmt.Sprintf("document %s has no field %s to replace its ID with", e.ID, e.Field)
+}
+
+// SetDocumentID sets the ID of doc to the ID in meta or, if the request
+// has a ReplaceField, to the key made of those fields. Fields may be
+// dotted paths into nested objects, or the _id, _index and _routing of
+// the document. The values of composite keys are joined with "/".
+//
+// Numeric values and composite keys also set the key of the document, so
+// that the documents are compared in the same order that Elasticsearch
+// sorts them in. With KeyIndex, the key starts with the index of the
+// document. The index and routing of the document are set in any case,
+// and so is its OriginalID if the ID is replaced.
+//
+// SetDocumentID returns false if the document has no value for a field
+// of the key and should be skipped, as requested by the MissingKey policy
+// of the request. With MissingKeyReport, the document is passed to the
+// OnMissingKey func of the request with its original ID.
+func SetDocumentID(req *IterateRequest, doc *diff.Document, meta DocumentMeta) (bool, error) {
+	doc.Index = meta.Index
+	doc.Routing = meta.Routing
+	ok, err := setDocumentKey(req, doc, meta)
+	if ok && req.KeyIndex {
+		doc.Key = meta.Index + keySeparator + doc.SortKey()
+	}
+	return ok, err
+}
+
+// setDocumentKey implements SetDocumentID except for KeyIndex.
+func setDocumentKey(req *IterateRequest, doc *diff.Document, meta DocumentMeta) (bool, error) {
+	fields := KeyFields(req)
+	if len(fields) == 0 {
+		doc.ID = meta.ID
+		return true, nil
+	}
+
+	source, err := keySource(doc, meta, fields)
+	if err != nil {
+		return false, err
+	}
+
+	ids := make([]string, len(fields))
+	keys := make([]string, len(fields))
+	var numeric bool
+	for i, field := range fields {
+		val, found := fieldValue(source, meta, field)
+		if !found {
+			switch req.MissingKey {
+			case MissingKeySkip:
+				return false, nil
+			case MissingKeyReport:
+				if req.OnMissingKey != nil {
+					doc.ID = meta.ID
+					req.OnMissingKey(doc)
+				}
+				return false, nil
+			default:
+				return false, MissingFieldError{ID: meta.ID, Field: field}
+			}
+		}
+		switch v := val.(type) {
+		case string:
+			ids[i], keys[i] = v, v
+		case bool:
+			// Elasticsearch sorts false before true
+			ids[i] = strconv.FormatBool(v)
+			if v {
+				keys[i] = NumericKey(1)
+			} else {
+				keys[i] = NumericKey(0)
+			}
+			numeric = true
+		default:
+			id, key, ok := numberKey(val)
+			if !ok {
+				return false, errors.Errorf("field %s of document %s has unsupported type %T to replace its ID with", field, meta.ID, val)
+			}
+			ids[i], keys[i] = id, key
+			numeric = true
+		}
+	}
+
+	doc.ID = strings.Join(ids, idSeparator)
+	doc.OriginalID = meta.ID
+	if numeric || len(fields) > 1 {
+		doc.Key = strings.Join(keys, keySeparator)
+	}
+	return true, nil
+}
+
+// keySource returns the source to read the fields of the key from. It
+// decodes the raw source of meta, if any, with numbers as json.Number,
+// unless all fields are metadata.
+func keySource(doc *diff.Document, meta DocumentMeta, fields []string) (map[string]interface{}, error) {
+	if len(meta.Source) == 0 {
+		return doc.Source, nil
+	}
+	for _, field := range fields {
+		switch field {
+		case "_id", "_index", "_routing":
+			continue
+		}
+		var source map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(meta.Source))
+		dec.UseNumber()
+		if err := dec.Decode(&source); err != nil {
+			return nil, errors.Wrapf(err, "unable to read the key of document %s", meta.ID)
+		}
+		return source, nil
+	}
+	return doc.Source, nil
+}
+
+// fieldValue returns the value of field, which is either a metadata field
+// like _id, _index or _routing, or a dotted path into source. Both nested
+// objects and dotted field names are supported, e.g. "order.id" finds
+// {"order":{"id":1}} as well as {"order.id":1}. Null values are missing.
+func fieldValue(source map[string]interface{}, meta DocumentMeta, field string) (interface{}, bool) {
+	switch field {
+	case "_id":
+		return meta.ID, true
+	case "_index":
+		return meta.Index, true
+	case "_routing":
+		// Documents without custom routing have an empty routing
+		return meta.Routing, true
+	}
+	return lookupPath(source, field)
+}
+
+// lookupPath returns the value at the dotted path in source.
+func lookupPath(source map[string]interface{}, path string) (interface{}, bool) {
+	if val, found := source[path]; found {
+		return val, val != nil
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if obj, ok := source[path[:i]].(map[string]interface{}); ok {
+			if val, found := lookupPath(obj, path[i+1:]); found {
+				return val, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// maxExactFloat is the magnitude from which on not every integer can be
+// represented by a float64.
+const maxExactFloat = 1 << 53
+
+// numberKey returns the ID and key of the number val, e.g. "42" for 42.0
+// and "1.5" for 1.5. It returns false if val is not a number.
+func numberKey(val interface{}) (id, key string, ok bool) {
+	switch v := val.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return strconv.FormatInt(n, 10), integerKey(n), true
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return "", "", false
+		}
+		id, key := floatKey(f)
+		return id, key, true
+	case int:
+		return strconv.Itoa(v), integerKey(int64(v)), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), integerKey(int64(v)), true
+	case int64:
+		return strconv.FormatInt(v, 10), integerKey(v), true
+	case float32:
+		id, key := floatKey(float64(v))
+		return id, key, true
+	case float64:
+		id, key := floatKey(v)
+		return id, key, true
+	}
+	return "", "", false
+}
+
+// floatKey returns the ID and key of f. Integral values get the same key
+// as the integer, so that e.g. 1.0 matches 1.
+func floatKey(f float64) (id, key string) {
+	if f == math.Trunc(f) && f >= -(1<<63) && f < 1<<63 {
+		n := int64(f)
+		return strconv.FormatInt(n, 10), integerKey(n)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), exactKey(f, 0)
+}
+
+// integerKey returns the key of n. Integers beyond 2^53 are rounded when
+// converted to a float64, so their key includes the difference to the
+// rounded value to keep it unique and in order.
+func integerKey(n int64) string {
+	f := float64(n)
+	var d int64
+	if n >= 0 {
+		// f may be 2^63, which overflows an int64
+		d = int64(uint64(n) - uint64(f))
+	} else {
+		d = n - int64(f)
+	}
+	return exactKey(f, d)
+}
+
+// exactKey returns the key of the number f + d, where d is the difference
+// of an integer to its float64 f. Numbers from 2^53 on are suffixed by d,
+// which is less than 2^11 in magnitude.
+func exactKey(f float64, d int64) string {
+	key := NumericKey(f)
+	if math.Abs(f) >= maxExactFloat {
+		key += fmt.Sprintf("%04x", d+0x8000)
+	}
+	return key
+}
+
+// NumericKey returns a key for f whose string order is the numeric order,
+// e.g. NumericKey(9) < NumericKey(10), including negative numbers and
+// fractions. The key is the IEEE 754 representation of f as zero-padded
+// hexadecimal, with the bits flipped so that they sort as unsigned.
+func NumericKey(f float64) string {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%016x", bits)
+}
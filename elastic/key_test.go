@@ -0,0 +1,252 @@
+package elastic
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+func TestSorts(t *testing.T) {
+	tests := []struct {
+		Req   IterateRequest
+		Sorts []Sort
+	}{
+		{Req: IterateRequest{}},
+		{Req: IterateRequest{SortField: "id"}, Sorts: []Sort{{Field: "id", Asc: true}}},
+		{Req: IterateRequest{SortField: "-id"}, Sorts: []Sort{{Field: "id", Asc: false}}},
+		{Req: IterateRequest{SortField: "tenant,-id"}, Sorts: []Sort{{Field: "tenant", Asc: true}, {Field: "id", Asc: false}}},
+		{Req: IterateRequest{ReplaceField: "sku"}, Sorts: []Sort{{Field: "sku", Asc: true}}},
+		{Req: IterateRequest{ReplaceField: "tenant, order.id"}, Sorts: []Sort{{Field: "tenant", Asc: true}, {Field: "order.id", Asc: true}}},
+		{Req: IterateRequest{ReplaceField: "_routing,_id"}, Sorts: []Sort{{Field: "_id", Asc: true}}},
+		{Req: IterateRequest{SortField: "-id", ReplaceField: "sku"}, Sorts: []Sort{{Field: "id", Asc: false}}},
+		{Req: IterateRequest{KeyIndex: true}, Sorts: []Sort{{Field: "_index", Asc: true}, {Field: "_id", Asc: true}}},
+		{Req: IterateRequest{KeyIndex: true, ReplaceField: "sku"}, Sorts: []Sort{{Field: "_index", Asc: true}, {Field: "sku", Asc: true}}},
+	}
+	for i, tt := range tests {
+		if have := Sorts(&tt.Req); !cmp.Equal(tt.Sorts, have) {
+			t.Errorf("#%d: %v", i, cmp.Diff(tt.Sorts, have))
+		}
+	}
+}
+
+func TestNeedsTiebreaker(t *testing.T) {
+	tests := []struct {
+		Req  IterateRequest
+		Want bool
+	}{
+		{Req: IterateRequest{}, Want: false},
+		{Req: IterateRequest{SortField: "-id"}, Want: true},
+		{Req: IterateRequest{ReplaceField: "_index,_id"}, Want: false},
+		{Req: IterateRequest{KeyIndex: true}, Want: false},
+	}
+	for i, tt := range tests {
+		if have := NeedsTiebreaker(&tt.Req); have != tt.Want {
+			t.Errorf("#%d: want %v, have %v", i, tt.Want, have)
+		}
+	}
+}
+
+func TestUnorderedSort(t *testing.T) {
+	tests := []struct {
+		Req         IterateRequest
+		PointInTime bool
+		Want        string
+	}{
+		{Req: IterateRequest{}, Want: ""},
+		{Req: IterateRequest{}, PointInTime: true, Want: ""},
+		{Req: IterateRequest{Unordered: true}, Want: "_doc"},
+		{Req: IterateRequest{Unordered: true}, PointInTime: true, Want: "_shard_doc"},
+		{Req: IterateRequest{Unordered: true, ReplaceField: "sku", KeyIndex: true}, Want: "_doc"},
+		{Req: IterateRequest{Unordered: true, SortField: "-id"}, Want: ""},
+	}
+	for i, tt := range tests {
+		if have := UnorderedSort(&tt.Req, tt.PointInTime); have != tt.Want {
+			t.Errorf("#%d: want %q, have %q", i, tt.Want, have)
+		}
+	}
+}
+
+func TestSetDocumentID(t *testing.T) {
+	meta := DocumentMeta{ID: "1", Index: "orders", Routing: "r1"}
+	tests := []struct {
+		ReplaceField string
+		Source       map[string]interface{}
+		ID, Key      string
+		Err          bool
+	}{
+		{Source: map[string]interface{}{"sku": "A-1"}, ID: "1"},
+		{ReplaceField: "sku", Source: map[string]interface{}{"sku": "A-1"}, ID: "A-1"},
+		{ReplaceField: "num", Source: map[string]interface{}{"num": 42.0}, ID: "42", Key: NumericKey(42)},
+		{ReplaceField: "num", Source: map[string]interface{}{"num": 1.5}, ID: "1.5", Key: NumericKey(1.5)},
+		{ReplaceField: "num", Source: map[string]interface{}{"num": int64(-7)}, ID: "-7", Key: NumericKey(-7)},
+		{ReplaceField: "active", Source: map[string]interface{}{"active": true}, ID: "true", Key: NumericKey(1)},
+		{ReplaceField: "order.id", Source: map[string]interface{}{"order": map[string]interface{}{"id": "o-1"}}, ID: "o-1"},
+		{ReplaceField: "order.id", Source: map[string]interface{}{"order.id": "o-1"}, ID: "o-1"},
+		{ReplaceField: "order.item.sku", Source: map[string]interface{}{"order": map[string]interface{}{"item.sku": "A-1"}}, ID: "A-1"},
+		{ReplaceField: "tenant,order_id", Source: map[string]interface{}{"tenant": "acme", "order_id": 10.0}, ID: "acme/10", Key: "acme\x00" + NumericKey(10)},
+		{ReplaceField: "_index,_id", Source: map[string]interface{}{}, ID: "orders/1", Key: "orders\x001"},
+		{ReplaceField: "_routing", Source: map[string]interface{}{}, ID: "r1"},
+		{ReplaceField: "sku", Source: map[string]interface{}{"name": "A"}, Err: true},
+		{ReplaceField: "sku", Source: map[string]interface{}{"sku": nil}, Err: true},
+		{ReplaceField: "order.id", Source: map[string]interface{}{"order": "o-1"}, Err: true},
+		{ReplaceField: "sku", Source: map[string]interface{}{"sku": []interface{}{"A"}}, Err: true},
+	}
+	for i, tt := range tests {
+		doc := &diff.Document{Source: tt.Source}
+		ok, err := SetDocumentID(&IterateRequest{ReplaceField: tt.ReplaceField}, doc, meta)
+		if tt.Err {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !ok {
+			t.Fatalf("#%d: expected the document not to be skipped", i)
+		}
+		if doc.ID != tt.ID || doc.Key != tt.Key {
+			t.Errorf("#%d: want ID %q and key %q, have ID %q and key %q", i, tt.ID, tt.Key, doc.ID, doc.Key)
+		}
+	}
+}
+
+func TestSetDocumentIDKeyIndex(t *testing.T) {
+	meta := DocumentMeta{ID: "1", Index: "logs-2024.01.01"}
+	tests := []struct {
+		ReplaceField string
+		Source       map[string]interface{}
+		ID, Key      string
+	}{
+		{Source: map[string]interface{}{}, ID: "1", Key: "logs-2024.01.01\x001"},
+		{ReplaceField: "num", Source: map[string]interface{}{"num": 9.0}, ID: "9", Key: "logs-2024.01.01\x00" + NumericKey(9)},
+	}
+	for i, tt := range tests {
+		doc := &diff.Document{Source: tt.Source}
+		req := &IterateRequest{ReplaceField: tt.ReplaceField, KeyIndex: true}
+		if ok, err := SetDocumentID(req, doc, meta); !ok || err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if doc.ID != tt.ID || doc.Key != tt.Key || doc.Index != meta.Index {
+			t.Errorf("#%d: want ID %q and key %q, have ID %q and key %q in index %q", i, tt.ID, tt.Key, doc.ID, doc.Key, doc.Index)
+		}
+	}
+}
+
+func TestSetDocumentIDMissingKey(t *testing.T) {
+	meta := DocumentMeta{ID: "1"}
+	source := map[string]interface{}{"tenant": "acme"}
+
+	// Fail
+	req := &IterateRequest{ReplaceField: "tenant,order_id"}
+	_, err := SetDocumentID(req, &diff.Document{Source: source}, meta)
+	if want := (MissingFieldError{ID: "1", Field: "order_id"}); err != want {
+		t.Fatalf("want error %v, have %v", want, err)
+	}
+
+	// Skip
+	req.MissingKey = MissingKeySkip
+	if ok, err := SetDocumentID(req, &diff.Document{Source: source}, meta); ok || err != nil {
+		t.Fatalf("want the document to be skipped, have %v and %v", ok, err)
+	}
+
+	// Report
+	var reported []string
+	req.MissingKey = MissingKeyReport
+	req.OnMissingKey = func(doc *diff.Document) {
+		reported = append(reported, doc.ID)
+	}
+	if ok, err := SetDocumentID(req, &diff.Document{Source: source}, meta); ok || err != nil {
+		t.Fatalf("want the document to be skipped, have %v and %v", ok, err)
+	}
+	if !cmp.Equal([]string{"1"}, reported) {
+		t.Fatalf("want the document to be reported, have %v", reported)
+	}
+}
+
+func TestParseMissingKeyPolicy(t *testing.T) {
+	for _, s := range []string{"", "error", "skip", "report", "SKIP"} {
+		if _, err := ParseMissingKeyPolicy(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+	}
+	if _, err := ParseMissingKeyPolicy("ignore"); err == nil {
+		t.Error("expected an error for an unknown missing key policy")
+	}
+}
+
+func TestSetDocumentIDLargeIntegers(t *testing.T) {
+	// Snowflake IDs beyond 2^53 that collapse as float64
+	raws := []string{
+		`{"id":-9007199254740993}`,
+		`{"id":-9007199254740992}`,
+		`{"id":-1.5}`,
+		`{"id":1}`,
+		`{"id":9007199254740991}`,
+		`{"id":9007199254740992}`,
+		`{"id":9007199254740993}`,
+		`{"id":9007199254740994}`,
+		`{"id":1.2e19}`,
+	}
+	ids := []string{
+		"-9007199254740993",
+		"-9007199254740992",
+		"-1.5",
+		"1",
+		"9007199254740991",
+		"9007199254740992",
+		"9007199254740993",
+		"9007199254740994",
+		"12000000000000000000",
+	}
+	var keys []string
+	for i, raw := range raws {
+		doc := new(diff.Document)
+		if err := json.Unmarshal([]byte(raw), &doc.Source); err != nil {
+			t.Fatal(err)
+		}
+		meta := DocumentMeta{ID: "doc", Source: json.RawMessage(raw)}
+		if _, err := SetDocumentID(&IterateRequest{ReplaceField: "id"}, doc, meta); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if doc.ID != ids[i] {
+			t.Errorf("#%d: want ID %q, have %q", i, ids[i], doc.ID)
+		}
+		keys = append(keys, doc.Key)
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	if !cmp.Equal(keys, sorted) {
+		t.Fatalf("keys are not in numeric order: %v", cmp.Diff(keys, sorted))
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] == keys[i] {
+			t.Fatalf("#%d and #%d have the same key %q", i-1, i, keys[i])
+		}
+	}
+
+	// Integral floats match integers
+	_, one, _ := numberKey(json.Number("1"))
+	_, oneFloat, _ := numberKey(1.0)
+	if one != oneFloat {
+		t.Fatalf("want 1 and 1.0 to have the same key, have %q and %q", one, oneFloat)
+	}
+}
+
+func TestNumericKey(t *testing.T) {
+	numbers := []float64{-1e10, -10, -9, -1.5, -0.25, 0, 0.25, 1, 1.5, 9, 10, 100, 1e10}
+	keys := make([]string, len(numbers))
+	for i, f := range numbers {
+		keys[i] = NumericKey(f)
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	if !cmp.Equal(keys, sorted) {
+		t.Fatalf("keys are not in numeric order: %v", cmp.Diff(keys, sorted))
+	}
+}
@@ -0,0 +1,63 @@
+package elastic
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// MappingClient is implemented by clients that can return the mapping
+// of their index.
+type MappingClient interface {
+	// Mapping returns the mapping of the index, i.e. the object with
+	// the properties of the fields, without the index name and type.
+	Mapping(context.Context) (map[string]interface{}, error)
+}
+
+// UnwrapMapping returns the mapping of a single index from the response
+// of the Get Mapping API, which nests the mapping below the index name
+// and, before Elasticsearch 7.x, the type. If typ is empty, the type is
+// detected if there is only one (except for _default_).
+func UnwrapMapping(res map[string]interface{}, typ string) (map[string]interface{}, error) {
+	if len(res) != 1 {
+		return nil, errors.Errorf("expected mapping of a single index, got %d", len(res))
+	}
+	var mappings map[string]interface{}
+	for index, v := range res {
+		m, _ := v.(map[string]interface{})
+		mappings, _ = m["mappings"].(map[string]interface{})
+		if mappings == nil {
+			return nil, errors.Errorf("no mappings found for index %s", index)
+		}
+	}
+
+	if typ != "" {
+		mapping, ok := mappings[typ].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("no mapping found for type %s", typ)
+		}
+		return mapping, nil
+	}
+
+	// Typeless mappings have properties at the top level
+	if _, found := mappings["properties"]; found || len(mappings) == 0 {
+		return mappings, nil
+	}
+	var (
+		mapping map[string]interface{}
+		types   int
+	)
+	for name, v := range mappings {
+		m, ok := v.(map[string]interface{})
+		if !ok || name == "_default_" {
+			continue
+		}
+		mapping = m
+		types++
+	}
+	if types != 1 {
+		// Not nested below a type
+		return mappings, nil
+	}
+	return mapping, nil
+}
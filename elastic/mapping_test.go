@@ -0,0 +1,77 @@
+package elastic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnwrapMapping(t *testing.T) {
+	mapping := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "text"},
+		},
+	}
+
+	tests := []struct {
+		Response map[string]interface{}
+		Type     string
+		Mapping  map[string]interface{}
+		Err      bool
+	}{
+		// #0 Typeless
+		{
+			Response: map[string]interface{}{"index01": map[string]interface{}{"mappings": mapping}},
+			Mapping:  mapping,
+		},
+		// #1 Typed
+		{
+			Response: map[string]interface{}{"index01": map[string]interface{}{"mappings": map[string]interface{}{"tweet": mapping}}},
+			Type:     "tweet",
+			Mapping:  mapping,
+		},
+		// #2 Typed with detection of the type
+		{
+			Response: map[string]interface{}{"index01": map[string]interface{}{"mappings": map[string]interface{}{
+				"_default_": map[string]interface{}{},
+				"tweet":     mapping,
+			}}},
+			Mapping: mapping,
+		},
+		// #3 Empty
+		{
+			Response: map[string]interface{}{"index01": map[string]interface{}{"mappings": map[string]interface{}{}}},
+			Mapping:  map[string]interface{}{},
+		},
+		// #4 Unknown type
+		{
+			Response: map[string]interface{}{"index01": map[string]interface{}{"mappings": map[string]interface{}{"tweet": mapping}}},
+			Type:     "doc",
+			Err:      true,
+		},
+		// #5 Multiple indices
+		{
+			Response: map[string]interface{}{
+				"index01": map[string]interface{}{"mappings": mapping},
+				"index02": map[string]interface{}{"mappings": mapping},
+			},
+			Err: true,
+		},
+	}
+
+	for i, tt := range tests {
+		have, err := UnwrapMapping(tt.Response, tt.Type)
+		if tt.Err {
+			if err == nil {
+				t.Fatalf("#%d: want error, have nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want := tt.Mapping; !cmp.Equal(want, have) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/opensearch-project/opensearch-go/v2/opensearchapi"
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Bulk sends the actions to the index in a single request to the Bulk API.
+func (c *Client) Bulk(ctx context.Context, req *elastic.BulkRequest) (*elastic.BulkResponse, error) {
+	var body bytes.Buffer
+	if err := elastic.WriteBulkBody(&body, req.Actions); err != nil {
+		return nil, err
+	}
+	options := []func(*opensearchapi.BulkRequest){
+		c.c.Bulk.WithContext(ctx),
+		c.c.Bulk.WithIndex(c.index),
+	}
+	if req.Refresh != "" {
+		options = append(options, c.c.Bulk.WithRefresh(req.Refresh))
+	}
+	res, err := c.c.Bulk(&body, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		data, _ := io.ReadAll(res.Body)
+		return nil, errors.Errorf("opensearch returned %s: %s", res.Status(), bytes.TrimSpace(data))
+	}
+	return elastic.ReadBulkResponse(res.Body)
+}
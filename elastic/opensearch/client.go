@@ -0,0 +1,507 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Masterminds/semver"
+	opensearchgo "github.com/opensearch-project/opensearch-go/v2"
+	"github.com/opensearch-project/opensearch-go/v2/opensearchapi"
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/transport"
+)
+
+const (
+	// keepAlive is the time a scroll context or point in time
+	// is kept open between two requests.
+	keepAlive       = 5 * time.Minute
+	keepAliveString = "5m"
+
+	// cleanupTimeout is the time we allow for clearing a scroll
+	// context or closing a point in time after iterating.
+	cleanupTimeout = 10 * time.Second
+)
+
+// Client implements an OpenSearch 1.x and 2.x client.
+//
+// OpenSearch 2.x no longer supports mapping types, so the type
+// in the configuration (if any) is ignored.
+type Client struct {
+	c     *opensearchgo.Client
+	index string
+	size  int
+}
+
+// NewClient creates a new Client.
+func NewClient(cfg *config.Config) (*Client, error) {
+	var options opensearchgo.Config
+	if cfg != nil {
+		if cfg.URL != "" {
+			options.Addresses = []string{cfg.URL}
+		}
+		var l logger
+		if cfg.Errorlog != "" {
+			f, err := os.OpenFile(cfg.Errorlog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to initialize error log")
+			}
+			l.errorlog = log.New(f, "", 0)
+		}
+		if cfg.Tracelog != "" {
+			f, err := os.OpenFile(cfg.Tracelog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to initialize trace log")
+			}
+			l.tracelog = log.New(f, "", 0)
+		}
+		if cfg.Infolog != "" {
+			f, err := os.OpenFile(cfg.Infolog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to initialize info log")
+			}
+			l.infolog = log.New(f, "", 0)
+		}
+		if l.errorlog != nil || l.infolog != nil || l.tracelog != nil {
+			options.Logger = &l
+		}
+		if cfg.Username != "" || cfg.Password != "" {
+			options.Username = cfg.Username
+			options.Password = cfg.Password
+		}
+		tr, err := transport.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		options.Transport = tr
+		options.DiscoverNodesOnStart = cfg.Sniff
+	}
+	cli, err := opensearchgo.NewClient(options)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		c:     cli,
+		index: cfg.Index,
+		size:  100,
+	}
+	return c, nil
+}
+
+// SetBatchSize specifies the size of a single scroll operation.
+func (c *Client) SetBatchSize(size int) {
+	c.size = size
+}
+
+// searchResponse is the part of the search and scroll response
+// we are interested in.
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	PitID    string `json:"pit_id"`
+	Hits     *struct {
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// searchHit is a single hit in a searchResponse.
+type searchHit struct {
+	ID      string          `json:"_id"`
+	Index   string          `json:"_index"`
+	Routing string          `json:"_routing"`
+	Source  json.RawMessage `json:"_source"`
+	Sort    []interface{}   `json:"sort"`
+}
+
+// Iterate iterates over the index.
+func (c *Client) Iterate(ctx context.Context, req *elastic.IterateRequest) (<-chan *diff.Document, <-chan error) {
+	docCh := make(chan *diff.Document, 1)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			close(docCh)
+			close(errCh)
+		}()
+
+		strategy := req.Strategy
+		if strategy == elastic.StrategyAuto {
+			ok, err := c.supportsPointInTime(ctx)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			// Unordered documents are only returned in index order
+			// by scrolls, see searchBody
+			if ok && elastic.UnorderedSort(req, false) == "" {
+				strategy = elastic.StrategyPointInTime
+			} else {
+				strategy = elastic.StrategyScroll
+			}
+		}
+
+		var err error
+		switch strategy {
+		case elastic.StrategyScroll:
+			err = c.scroll(ctx, req, docCh)
+		case elastic.StrategyPointInTime:
+			err = c.searchAfter(ctx, req, docCh)
+		default:
+			err = errors.Errorf("unsupported iterate strategy %q", strategy)
+		}
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return docCh, errCh
+}
+
+// supportsPointInTime returns true if the cluster supports
+// point in time, which was added in OpenSearch 2.4.
+func (c *Client) supportsPointInTime(ctx context.Context) (bool, error) {
+	res, err := c.c.Info(c.c.Info.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := decodeResponse(res, &info); err != nil {
+		return false, err
+	}
+	v, err := semver.NewVersion(info.Version.Number)
+	if err != nil {
+		return false, err
+	}
+	if v.Major() == 7 {
+		// OpenSearch in compatibility mode reports a version of 7.10.2
+		return false, nil
+	}
+	return v.Major() > 2 || (v.Major() == 2 && v.Minor() >= 4), nil
+}
+
+// scroll iterates over the index with the Scroll API, reading
+// req.Slices slices in parallel if requested.
+func (c *Client) scroll(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	if req.Slices <= 1 {
+		return c.scrollSlice(ctx, req, nil, docCh)
+	}
+	return elastic.IterateSlices(ctx, req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		return c.scrollSlice(ctx, req, sliceQuery(slice, req.Slices), docCh)
+	})
+}
+
+// scrollSlice iterates over a single slice of the index with the
+// Scroll API, or over the whole index if slice is nil. It clears
+// the scroll context when done.
+func (c *Client) scrollSlice(ctx context.Context, req *elastic.IterateRequest, slice map[string]interface{}, docCh chan<- *diff.Document) error {
+	body := searchBody(req, false)
+	if slice != nil {
+		body["slice"] = slice
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var scrollID string
+	defer func() {
+		if scrollID == "" {
+			return
+		}
+		// Use a separate context as ctx might have been canceled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		res, err := c.c.ClearScroll(
+			c.c.ClearScroll.WithContext(cleanupCtx),
+			c.c.ClearScroll.WithScrollID(scrollID),
+		)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	res, err := c.c.Search(
+		c.c.Search.WithContext(ctx),
+		c.c.Search.WithIndex(c.index),
+		c.c.Search.WithSize(c.size),
+		c.c.Search.WithScroll(keepAlive),
+		c.c.Search.WithBody(bytes.NewReader(data)),
+	)
+	for {
+		if err != nil {
+			return err
+		}
+		var sr searchResponse
+		if err := decodeResponse(res, &sr); err != nil {
+			return err
+		}
+		if sr.ScrollID != "" {
+			scrollID = sr.ScrollID
+		}
+
+		if sr.Hits == nil {
+			return errors.New("unexpected nil hits")
+		}
+		if len(sr.Hits.Hits) == 0 {
+			return nil
+		}
+
+		for _, hit := range sr.Hits.Hits {
+			doc, err := newDocument(req, hit)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				// Skipped as it has no key
+				continue
+			}
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		res, err = c.c.Scroll(
+			c.c.Scroll.WithContext(ctx),
+			c.c.Scroll.WithScrollID(scrollID),
+			c.c.Scroll.WithScroll(keepAlive),
+		)
+	}
+}
+
+// searchAfter iterates over the index with a point in time and
+// search_after, reading req.Slices slices in parallel if requested.
+// It closes the point in time when done.
+func (c *Client) searchAfter(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	res, pit, err := c.c.PointInTime.Create(
+		c.c.PointInTime.Create.WithIndex(c.index),
+		c.c.PointInTime.Create.WithKeepAlive(keepAlive),
+		c.c.PointInTime.Create.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.IsError() || pit == nil || pit.PitID == "" {
+		return errors.Errorf("opensearch returned %s when creating a point in time", res.Status())
+	}
+	pitID := pit.PitID
+
+	defer func() {
+		// Use a separate context as ctx might have been canceled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		res, _, err := c.c.PointInTime.Delete(
+			c.c.PointInTime.Delete.WithPitID(pitID),
+			c.c.PointInTime.Delete.WithContext(cleanupCtx),
+		)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	if req.Slices <= 1 {
+		return c.searchAfterSlice(ctx, req, pitID, nil, docCh)
+	}
+	return elastic.IterateSlices(ctx, req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		return c.searchAfterSlice(ctx, req, pitID, sliceQuery(slice, req.Slices), docCh)
+	})
+}
+
+// searchAfterSlice iterates over a single slice of the point in time
+// with search_after, or over all of it if slice is nil.
+func (c *Client) searchAfterSlice(ctx context.Context, req *elastic.IterateRequest, pitID string, slice map[string]interface{}, docCh chan<- *diff.Document) error {
+	var searchAfter []interface{}
+	for {
+		body := searchBody(req, true)
+		body["size"] = c.size
+		if elastic.NeedsTiebreaker(req) {
+			// Sort by _id as a tiebreaker so that search_after doesn't
+			// skip documents with the same value in the sort field
+			body["sort"] = append(body["sort"].([]interface{}), map[string]interface{}{"_id": map[string]interface{}{"order": "asc"}})
+		}
+		body["pit"] = map[string]interface{}{
+			"id":         pitID,
+			"keep_alive": keepAliveString,
+		}
+		if slice != nil {
+			body["slice"] = slice
+		}
+		if len(searchAfter) > 0 {
+			body["search_after"] = searchAfter
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		res, err := c.c.Search(
+			c.c.Search.WithContext(ctx),
+			c.c.Search.WithBody(bytes.NewReader(data)),
+		)
+		if err != nil {
+			return err
+		}
+		var sr searchResponse
+		if err := decodeResponse(res, &sr); err != nil {
+			return err
+		}
+		if sr.PitID != "" {
+			pitID = sr.PitID
+		}
+
+		if sr.Hits == nil {
+			return errors.New("unexpected nil hits")
+		}
+		if len(sr.Hits.Hits) == 0 {
+			return nil
+		}
+
+		for _, hit := range sr.Hits.Hits {
+			doc, err := newDocument(req, hit)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				// Skipped as it has no key
+				continue
+			}
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		searchAfter = sr.Hits.Hits[len(sr.Hits.Hits)-1].Sort
+	}
+}
+
+// searchBody returns the body of the search request with sorting,
+// query and source filtering.
+func searchBody(req *elastic.IterateRequest, pointInTime bool) map[string]interface{} {
+	// Sorting
+	sorts := elastic.Sorts(req)
+	if field := elastic.UnorderedSort(req, false); field != "" && !pointInTime {
+		// OpenSearch can't sort by _shard_doc, so only scrolls
+		// return unordered documents in index order
+		sorts = []elastic.Sort{{Field: field, Asc: true}}
+	}
+	var sorters []interface{}
+	for _, sort := range sorts {
+		order := "asc"
+		if !sort.Asc {
+			order = "desc"
+		}
+		sorters = append(sorters, map[string]interface{}{sort.Field: map[string]interface{}{"order": order}})
+	}
+	if len(sorters) == 0 {
+		sorters = []interface{}{map[string]interface{}{"_id": map[string]interface{}{"order": "asc"}}}
+	}
+
+	body := map[string]interface{}{
+		"sort": sorters,
+	}
+
+	if req.RawQuery != "" {
+		body["query"] = json.RawMessage(req.RawQuery)
+	}
+
+	if len(req.SourceFilterInclude)+len(req.SourceFilterExclude) > 0 {
+		fsc := make(map[string]interface{})
+		if len(req.SourceFilterInclude) > 0 {
+			fsc["includes"] = req.SourceFilterInclude
+		}
+		if len(req.SourceFilterExclude) > 0 {
+			fsc["excludes"] = req.SourceFilterExclude
+		}
+		body["_source"] = fsc
+	}
+
+	return body
+}
+
+// sliceQuery returns the slice with the given id out of max slices.
+func sliceQuery(id, max int) map[string]interface{} {
+	return map[string]interface{}{"id": id, "max": max}
+}
+
+// newDocument creates a document from a search hit. It returns nil
+// if the document is skipped because it has no key.
+func newDocument(req *elastic.IterateRequest, hit searchHit) (*diff.Document, error) {
+	doc := new(diff.Document)
+	err := json.Unmarshal(hit.Source, &doc.Source)
+	if err != nil {
+		return nil, err
+	}
+	// Replace ID field with some other field from the document?
+	meta := elastic.DocumentMeta{ID: hit.ID, Index: hit.Index, Routing: hit.Routing, Source: hit.Source}
+	if ok, err := elastic.SetDocumentID(req, doc, meta); !ok || err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeResponse checks the response for errors and decodes
+// its body into v.
+func decodeResponse(res *opensearchapi.Response, v interface{}) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return errors.Errorf("opensearch returned %s: %s", res.Status(), bytes.TrimSpace(body))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// logger maps the error, info and trace logs of the configuration
+// to the logger interface of the OpenSearch client.
+type logger struct {
+	errorlog *log.Logger
+	infolog  *log.Logger
+	tracelog *log.Logger
+}
+
+// LogRoundTrip logs a single request and its response.
+func (l *logger) LogRoundTrip(req *http.Request, res *http.Response, err error, start time.Time, dur time.Duration) error {
+	var status int
+	if res != nil {
+		status = res.StatusCode
+	}
+	if l.errorlog != nil && (err != nil || status >= 400) {
+		l.errorlog.Printf("%s %s %s [status:%d, request:%s]: %v", start.Format(time.RFC3339), req.Method, req.URL, status, dur, err)
+	}
+	if l.infolog != nil {
+		l.infolog.Printf("%s %s [status:%d, request:%s]", req.Method, req.URL, status, dur)
+	}
+	if l.tracelog != nil {
+		l.tracelog.Printf("%s %s [status:%d, request:%s]", req.Method, req.URL, status, dur)
+		if req.Body != nil && req.Body != http.NoBody {
+			body, _ := io.ReadAll(req.Body)
+			l.tracelog.Printf("%s", body)
+		}
+		if res != nil && res.Body != nil && res.Body != http.NoBody {
+			body, _ := io.ReadAll(res.Body)
+			l.tracelog.Printf("%s", body)
+		}
+	}
+	return nil
+}
+
+// RequestBodyEnabled passes the request body to the logger if tracing is enabled.
+func (l *logger) RequestBodyEnabled() bool { return l.tracelog != nil }
+
+// ResponseBodyEnabled passes the response body to the logger if tracing is enabled.
+func (l *logger) ResponseBodyEnabled() bool { return l.tracelog != nil }
@@ -0,0 +1,38 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// IndexSettings returns the settings and aliases of the index, and the
+// index templates of the cluster.
+func (c *Client) IndexSettings(ctx context.Context) (*elastic.IndexSettings, error) {
+	return elastic.LoadIndexSettings(ctx, c.index, c.get)
+}
+
+// get performs a GET request for path and decodes the response into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := c.c.Perform(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return false, nil
+	case res.StatusCode > 299:
+		body, _ := io.ReadAll(res.Body)
+		return false, elastic.StatusError{StatusCode: res.StatusCode, Body: string(bytes.TrimSpace(body))}
+	}
+	return true, json.NewDecoder(res.Body).Decode(v)
+}
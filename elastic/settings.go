@@ -0,0 +1,148 @@
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// IndexSettings are the settings and aliases of an index, together with
+// the index templates of its cluster.
+type IndexSettings struct {
+	// Index is the name of the index, e.g. if the client accesses
+	// the index via an alias.
+	Index string `json:"-"`
+	// Settings are the settings of the index, e.g. {"index":{"number_of_shards":"1"}}.
+	Settings map[string]interface{} `json:"settings"`
+	// Aliases are the definitions of the aliases of the index by name.
+	Aliases map[string]interface{} `json:"aliases"`
+	// Templates are the legacy index templates by name.
+	Templates map[string]interface{} `json:"templates"`
+	// IndexTemplates are the composable index templates by name, which
+	// are supported as of Elasticsearch 7.8.
+	IndexTemplates map[string]interface{} `json:"index_templates"`
+}
+
+// SettingsClient is implemented by clients that can return the settings
+// and aliases of their index and the templates of their cluster.
+type SettingsClient interface {
+	IndexSettings(context.Context) (*IndexSettings, error)
+}
+
+// GetFunc performs a GET request for path and decodes the JSON response
+// into v. It returns false if the cluster responds with 404 Not Found,
+// and a StatusError for every other status of 400 or above.
+type GetFunc func(ctx context.Context, path string, v interface{}) (bool, error)
+
+// StatusError is returned by a GetFunc if the cluster responds with an
+// error status other than 404 Not Found.
+type StatusError struct {
+	StatusCode int
+	// Body is the body of the response.
+	Body string
+}
+
+// Error returns a description of the error.
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// unsupported returns nil if err is a StatusError indicating that the
+// cluster doesn't support the endpoint, i.e. 400 Bad Request or 405
+// Method Not Allowed, and err otherwise.
+func unsupported(err error) error {
+	if e, ok := err.(StatusError); ok && (e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusMethodNotAllowed) {
+		return nil
+	}
+	return err
+}
+
+// LoadIndexSettings loads the IndexSettings for index, performing the
+// requests via get.
+func LoadIndexSettings(ctx context.Context, index string, get GetFunc) (*IndexSettings, error) {
+	type indexType struct {
+		Settings map[string]interface{} `json:"settings"`
+		Aliases  map[string]interface{} `json:"aliases"`
+	}
+	s := &IndexSettings{
+		Aliases:        make(map[string]interface{}),
+		Templates:      make(map[string]interface{}),
+		IndexTemplates: make(map[string]interface{}),
+	}
+	escaped := "/" + url.PathEscape(index)
+
+	var settings map[string]indexType
+	found, err := get(ctx, path.Join(escaped, "_settings"), &settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get settings")
+	}
+	if !found {
+		return nil, errors.Errorf("index %s not found", index)
+	}
+	if len(settings) != 1 {
+		return nil, errors.Errorf("expected settings of a single index, got %d", len(settings))
+	}
+	for name, v := range settings {
+		s.Index = name
+		s.Settings = v.Settings
+	}
+
+	var aliases map[string]indexType
+	if _, err := get(ctx, path.Join(escaped, "_alias"), &aliases); err != nil {
+		return nil, errors.Wrap(err, "unable to get aliases")
+	}
+	for _, v := range aliases {
+		for name, alias := range v.Aliases {
+			s.Aliases[name] = alias
+		}
+	}
+
+	// Template endpoints may not be supported by the cluster
+	if _, err := get(ctx, "/_template", &s.Templates); unsupported(err) != nil {
+		return nil, errors.Wrap(err, "unable to get templates")
+	}
+
+	var indexTemplates struct {
+		IndexTemplates []struct {
+			Name          string                 `json:"name"`
+			IndexTemplate map[string]interface{} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if _, err := get(ctx, "/_index_template", &indexTemplates); unsupported(err) != nil {
+		return nil, errors.Wrap(err, "unable to get index templates")
+	}
+	for _, t := range indexTemplates.IndexTemplates {
+		s.IndexTemplates[t.Name] = t.IndexTemplate
+	}
+
+	return s, nil
+}
+
+// TemplatePatterns returns the index patterns of an index template,
+// i.e. the index_patterns as of Elasticsearch 6.x or the template of
+// Elasticsearch 5.x.
+func TemplatePatterns(template interface{}) []string {
+	t, ok := template.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var patterns []string
+	switch v := t["index_patterns"].(type) {
+	case string:
+		patterns = append(patterns, v)
+	case []interface{}:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				patterns = append(patterns, s)
+			}
+		}
+	}
+	if s, ok := t["template"].(string); ok {
+		patterns = append(patterns, s)
+	}
+	return patterns
+}
@@ -0,0 +1,72 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadIndexSettings(t *testing.T) {
+	responses := map[string]string{
+		"/alias01/_settings": `{"index01":{"settings":{"index":{"number_of_shards":"1","uuid":"abc"}}}}`,
+		"/alias01/_alias":    `{"index01":{"aliases":{"alias01":{},"filtered":{"filter":{"term":{"user":"olivere"}}}}}}`,
+		"/_template":         `{"legacy":{"index_patterns":["index*"],"settings":{}},"v5":{"template":"logs-*"}}`,
+	}
+	errs := map[string]error{
+		// Not supported by the cluster
+		"/_index_template": StatusError{StatusCode: http.StatusBadRequest, Body: `{"error":"no handler found"}`},
+		// Malformed request
+		"/bad/_settings": StatusError{StatusCode: http.StatusBadRequest, Body: `{"error":"invalid_index_name_exception"}`},
+	}
+	get := func(ctx context.Context, path string, v interface{}) (bool, error) {
+		if err, found := errs[path]; found {
+			return false, err
+		}
+		body, found := responses[path]
+		if !found {
+			return false, nil
+		}
+		return true, json.Unmarshal([]byte(body), v)
+	}
+
+	s, err := LoadIndexSettings(context.Background(), "alias01", get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &IndexSettings{
+		Index: "index01",
+		Settings: map[string]interface{}{
+			"index": map[string]interface{}{"number_of_shards": "1", "uuid": "abc"},
+		},
+		Aliases: map[string]interface{}{
+			"alias01":  map[string]interface{}{},
+			"filtered": map[string]interface{}{"filter": map[string]interface{}{"term": map[string]interface{}{"user": "olivere"}}},
+		},
+		Templates: map[string]interface{}{
+			"legacy": map[string]interface{}{"index_patterns": []interface{}{"index*"}, "settings": map[string]interface{}{}},
+			"v5":     map[string]interface{}{"template": "logs-*"},
+		},
+		IndexTemplates: map[string]interface{}{},
+	}
+	if !cmp.Equal(want, s) {
+		t.Fatal(cmp.Diff(want, s))
+	}
+
+	if want, have := []string{"index*"}, TemplatePatterns(s.Templates["legacy"]); !cmp.Equal(want, have) {
+		t.Errorf("TemplatePatterns: %v", cmp.Diff(want, have))
+	}
+	if want, have := []string{"logs-*"}, TemplatePatterns(s.Templates["v5"]); !cmp.Equal(want, have) {
+		t.Errorf("TemplatePatterns: %v", cmp.Diff(want, have))
+	}
+
+	if _, err := LoadIndexSettings(context.Background(), "missing", get); err == nil || !strings.Contains(err.Error(), "index missing not found") {
+		t.Fatalf("want error for missing index, have %v", err)
+	}
+	if _, err := LoadIndexSettings(context.Background(), "bad", get); err == nil || !strings.Contains(err.Error(), "400 Bad Request: {\"error\":\"invalid_index_name_exception\"}") {
+		t.Fatalf("want error for bad request, have %v", err)
+	}
+}
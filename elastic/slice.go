@@ -0,0 +1,149 @@
+package elastic
+
+import (
+	"container/heap"
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+// SliceFunc reads all documents of a single slice, sorted by key (see
+// diff.Document.SortKey) in the direction of the request, and passes them
+// to docCh. It must not close docCh.
+type SliceFunc func(ctx context.Context, slice int, docCh chan<- *diff.Document) error
+
+// IterateSlices reads the req.Slices slices of req in parallel by running
+// fn for each slice, then merges the documents of all slices into docCh.
+// As the documents of each slice are sorted by key, the merged stream is
+// sorted by key as well, which is what diff.Differ expects. The documents
+// are merged in descending order if req sorts in descending order (see
+// Sorts), so the stream is in the same order as without slices.
+func IterateSlices(ctx context.Context, req *IterateRequest, docCh chan<- *diff.Document, fn SliceFunc) error {
+	desc, err := sliceOrder(req)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	n := req.Slices
+
+	chs := make([]<-chan *diff.Document, n)
+	for i := 0; i < n; i++ {
+		slice := i
+		ch := make(chan *diff.Document, 1)
+		chs[i] = ch
+		g.Go(func() error {
+			defer close(ch)
+			return fn(ctx, slice, ch)
+		})
+	}
+
+	g.Go(func() error {
+		return mergeSorted(ctx, chs, docCh, desc)
+	})
+
+	return g.Wait()
+}
+
+// sliceOrder returns true if the slices of req are sorted in descending
+// order. The merge compares whole keys, so all sort fields must have the
+// same direction, unless req is Unordered.
+func sliceOrder(req *IterateRequest) (bool, error) {
+	if UnorderedSort(req, false) != "" {
+		return false, nil
+	}
+	sorts := Sorts(req)
+	if len(sorts) == 0 {
+		return false, nil
+	}
+	desc := !sorts[0].Asc
+	for _, sort := range sorts[1:] {
+		if sort.Asc == desc && !req.Unordered {
+			return false, errors.Errorf("unable to merge slices sorted by %s: all sort fields must be either ascending or descending", req.SortField)
+		}
+	}
+	return desc, nil
+}
+
+// mergeSorted merges the documents of chs, each of which is sorted
+// by key in ascending order (or descending order if desc is true),
+// into docCh.
+func mergeSorted(ctx context.Context, chs []<-chan *diff.Document, docCh chan<- *diff.Document, desc bool) error {
+	// next reads the next document from ch, or nil when ch is exhausted.
+	next := func(ch <-chan *diff.Document) (*diff.Document, error) {
+		select {
+		case doc, ok := <-ch:
+			if !ok {
+				return nil, nil
+			}
+			return doc, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	h := &sliceHeap{heads: make([]sliceHead, 0, len(chs)), desc: desc}
+	for _, ch := range chs {
+		doc, err := next(ch)
+		if err != nil {
+			return err
+		}
+		if doc != nil {
+			h.heads = append(h.heads, sliceHead{doc: doc, ch: ch})
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		head := h.heads[0]
+		select {
+		case docCh <- head.doc:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		doc, err := next(head.ch)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			heap.Pop(h)
+		} else {
+			h.heads[0].doc = doc
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+// sliceHead is the current document of a slice.
+type sliceHead struct {
+	doc *diff.Document
+	ch  <-chan *diff.Document
+}
+
+// sliceHeap is a min-heap of slices, ordered by the key of their
+// current document, or a max-heap if desc is true.
+type sliceHeap struct {
+	heads []sliceHead
+	desc  bool
+}
+
+func (h *sliceHeap) Len() int { return len(h.heads) }
+func (h *sliceHeap) Less(i, j int) bool {
+	if h.desc {
+		return h.heads[i].doc.SortKey() > h.heads[j].doc.SortKey()
+	}
+	return h.heads[i].doc.SortKey() < h.heads[j].doc.SortKey()
+}
+func (h *sliceHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *sliceHeap) Push(x interface{}) { h.heads = append(h.heads, x.(sliceHead)) }
+func (h *sliceHeap) Pop() interface{} {
+	n := len(h.heads)
+	x := h.heads[n-1]
+	h.heads = h.heads[:n-1]
+	return x
+}
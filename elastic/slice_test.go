@@ -0,0 +1,216 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+)
+
+var iterateSlicesTests = []struct {
+	SortField string
+	Slices    [][]string
+	IDs       []string
+}{
+	// #0
+	{
+		Slices: [][]string{nil},
+		IDs:    nil,
+	},
+	// #1
+	{
+		Slices: [][]string{{"1", "2", "3"}},
+		IDs:    []string{"1", "2", "3"},
+	},
+	// #2
+	{
+		Slices: [][]string{{"1", "4", "7"}, {"2", "5"}, {"3", "6", "8", "9"}},
+		IDs:    []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+	},
+	// #3
+	{
+		Slices: [][]string{{"10", "30"}, nil, {"20"}, {"05", "40"}},
+		IDs:    []string{"05", "10", "20", "30", "40"},
+	},
+	// #4
+	{
+		SortField: "-_id",
+		Slices:    [][]string{{"7", "4", "1"}, {"5", "2"}, {"9", "8", "6", "3"}},
+		IDs:       []string{"9", "8", "7", "6", "5", "4", "3", "2", "1"},
+	},
+}
+
+func TestIterateSlices(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	for i, tt := range iterateSlicesTests {
+		docCh := make(chan *diff.Document)
+		errCh := make(chan error, 1)
+		go func() {
+			defer close(docCh)
+			req := &IterateRequest{Slices: len(tt.Slices), SortField: tt.SortField}
+			errCh <- IterateSlices(context.Background(), req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+				for _, id := range tt.Slices[slice] {
+					docCh <- &diff.Document{ID: id}
+				}
+				return nil
+			})
+		}()
+
+		var ids []string
+		timeout := time.After(5 * time.Second)
+		for done := false; !done; {
+			select {
+			case doc, ok := <-docCh:
+				if !ok {
+					done = true
+					break
+				}
+				ids = append(ids, doc.ID)
+			case <-timeout:
+				t.Fatalf("#%d: timeout", i)
+			}
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("#%d: want no error, have %v", i, err)
+		}
+		if want, have := tt.IDs, ids; !cmp.Equal(want, have) {
+			t.Fatalf("#%d: IDs: %v", i, cmp.Diff(want, have))
+		}
+	}
+}
+
+// mergeSlices merges slices with IterateSlices and returns the result.
+func mergeSlices(t *testing.T, slices [][]*diff.Document) []*diff.Document {
+	t.Helper()
+	docCh := make(chan *diff.Document)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(docCh)
+		errCh <- IterateSlices(context.Background(), &IterateRequest{Slices: len(slices)}, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+			for _, doc := range slices[slice] {
+				docCh <- doc
+			}
+			return nil
+		})
+	}()
+
+	var docs []*diff.Document
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case doc, ok := <-docCh:
+			if !ok {
+				if err := <-errCh; err != nil {
+					t.Fatalf("want no error, have %v", err)
+				}
+				return docs
+			}
+			docs = append(docs, doc)
+		case <-timeout:
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestIterateSlicesByKey(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// Numeric IDs, e.g. from -replace-with, are merged in numeric order
+	doc := func(n float64, id string) *diff.Document {
+		return &diff.Document{ID: id, Key: NumericKey(n)}
+	}
+	slices := [][]*diff.Document{
+		{doc(2, "2"), doc(10, "10")},
+		{doc(9, "9"), doc(100, "100")},
+		{doc(-1, "-1"), doc(1.5, "1.5")},
+	}
+	var ids []string
+	for _, doc := range mergeSlices(t, slices) {
+		ids = append(ids, doc.ID)
+	}
+	if want := []string{"-1", "1.5", "2", "9", "10", "100"}; !cmp.Equal(want, ids) {
+		t.Fatalf("IDs: %v", cmp.Diff(want, ids))
+	}
+}
+
+func TestIterateSlicesKeyIndex(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// Documents of an index pattern are merged by index, then by ID
+	req := &IterateRequest{KeyIndex: true}
+	doc := func(index, id string) *diff.Document {
+		doc := new(diff.Document)
+		if _, err := SetDocumentID(req, doc, DocumentMeta{ID: id, Index: index}); err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	slices := [][]*diff.Document{
+		{doc("logs-2024.01.01", "3"), doc("logs-2024.01.02", "1")},
+		{doc("logs-2024.01.01", "1"), doc("logs-2024.01.02", "2"), doc("logs-2024.01.03", "0")},
+		{doc("logs-2024.01.02", "0")},
+	}
+	var keys []string
+	for _, doc := range mergeSlices(t, slices) {
+		keys = append(keys, doc.Index+"/"+doc.ID)
+	}
+	want := []string{
+		"logs-2024.01.01/1",
+		"logs-2024.01.01/3",
+		"logs-2024.01.02/0",
+		"logs-2024.01.02/1",
+		"logs-2024.01.02/2",
+		"logs-2024.01.03/0",
+	}
+	if !cmp.Equal(want, keys) {
+		t.Fatalf("keys: %v", cmp.Diff(want, keys))
+	}
+}
+
+func TestIterateSlicesError(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	failure := errors.New("slice failed")
+	docCh := make(chan *diff.Document)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(docCh)
+		errCh <- IterateSlices(context.Background(), &IterateRequest{Slices: 3}, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+			if slice == 1 {
+				return failure
+			}
+			// Block until the failing slice cancels the context
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	for range docCh {
+	}
+	if want, have := failure, <-errCh; want != have {
+		t.Fatalf("want error %v, have %v", want, have)
+	}
+}
+
+func TestIterateSlicesMixedOrder(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	fn := func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		return nil
+	}
+	docCh := make(chan *diff.Document)
+	err := IterateSlices(context.Background(), &IterateRequest{Slices: 2, SortField: "-date,id"}, docCh, fn)
+	if err == nil {
+		t.Fatal("want error for sort fields with mixed directions")
+	}
+	err = IterateSlices(context.Background(), &IterateRequest{Slices: 2, SortField: "-date,id", Unordered: true}, docCh, fn)
+	if err != nil {
+		t.Fatalf("want no error for unordered request, have %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// sigV4Algorithm is the algorithm of AWS Signature Version 4.
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// sigV4TimeFormat is the format of the X-Amz-Date header.
+	sigV4TimeFormat = "20060102T150405Z"
+	// sigV4DefaultService is the service name of Amazon OpenSearch Service.
+	sigV4DefaultService = "es"
+	// sigV4ServerlessService is the service name of Amazon OpenSearch
+	// Serverless, which requires the X-Amz-Content-Sha256 header.
+	sigV4ServerlessService = "aoss"
+)
+
+// AWSCredentials are the credentials to sign requests with.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// LoadAWSCredentials loads the AWS credentials from the environment
+// variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+// or from the given profile in the shared credentials file
+// (~/.aws/credentials or AWS_SHARED_CREDENTIALS_FILE). If profile is
+// empty, AWS_PROFILE or "default" is used.
+func LoadAWSCredentials(profile string) (AWSCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return AWSCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: secret,
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	profile = awsProfile(profile)
+	filename := awsFile("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+	section, err := readINISection(filename, profile)
+	if err != nil {
+		return AWSCredentials{}, errors.Wrap(err, "unable to load AWS credentials")
+	}
+	creds := AWSCredentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.Errorf("no AWS credentials found for profile %q in %s", profile, filename)
+	}
+	return creds, nil
+}
+
+// LoadAWSRegion loads the AWS region from the environment variables
+// AWS_REGION or AWS_DEFAULT_REGION, or from the given profile in the
+// shared config file (~/.aws/config or AWS_CONFIG_FILE).
+func LoadAWSRegion(profile string) (string, error) {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region, nil
+		}
+	}
+
+	profile = awsProfile(profile)
+	filename := awsFile("AWS_CONFIG_FILE", "config")
+	name := profile
+	if name != "default" {
+		name = "profile " + profile
+	}
+	section, err := readINISection(filename, name)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to load AWS region")
+	}
+	if region := section["region"]; region != "" {
+		return region, nil
+	}
+	return "", errors.Errorf("no AWS region found for profile %q in %s", profile, filename)
+}
+
+// awsProfile returns the profile to use if profile is empty.
+func awsProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if profile = os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// awsFile returns the path of a shared AWS file, which can be
+// overridden by the environment variable env.
+func awsFile(env, name string) string {
+	if filename := os.Getenv(env); filename != "" {
+		return filename
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".aws", name)
+}
+
+// readINISection reads the keys and values of a section in an INI file.
+func readINISection(filename, name string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		section map[string]string
+		found   bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if found {
+				break
+			}
+			if strings.TrimSpace(line[1:len(line)-1]) == name {
+				found = true
+				section = make(map[string]string)
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			section[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("profile %q not found in %s", name, filename)
+	}
+	return section, nil
+}
+
+// signingTransport signs every request with AWS Signature Version 4.
+type signingTransport struct {
+	next    http.RoundTripper
+	creds   AWSCredentials
+	region  string
+	service string
+	now     func() time.Time
+}
+
+// RoundTrip signs a copy of req and passes it on.
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	signRequest(req, body, t.creds, t.region, t.service, t.now())
+	return t.next.RoundTrip(req)
+}
+
+// signRequest adds the headers for AWS Signature Version 4 to req,
+// which has the given body.
+func signRequest(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	date := amzDate[:8]
+	payloadHash := hashHex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if service == sigV4ServerlessService {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalURI returns the path of u for the canonical request. Like
+// the AWS SDKs, it encodes the already escaped path once more.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// canonicalQuery returns the query string of u for the canonical request.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes s as specified for AWS Signature Version 4, i.e.
+// every byte except the unreserved characters is percent-encoded.
+// Slashes are kept unless encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// hashHex returns the SHA-256 hash of data, encoded in hex.
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,191 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olivere/esdiff/elastic/config"
+)
+
+// exampleCredentials are the credentials of the AWS Signature Version 4
+// test suite.
+var exampleCredentials = AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func TestSignRequest(t *testing.T) {
+	// get-vanilla of the AWS Signature Version 4 test suite
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signRequest(req, nil, exampleCredentials, "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if have := req.Header.Get("Authorization"); want != have {
+		t.Fatalf("want\n%s\nhave\n%s", want, have)
+	}
+	if want, have := "20150830T123600Z", req.Header.Get("X-Amz-Date"); want != have {
+		t.Fatalf("X-Amz-Date: want %q, have %q", want, have)
+	}
+}
+
+func TestSigningTransport(t *testing.T) {
+	creds := exampleCredentials
+	creds.SessionToken = "token"
+
+	// verify recomputes the signature of the request as received by the server
+	verify := func(t *testing.T, r *http.Request, service string) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now, err := time.Parse(sigV4TimeFormat, r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(r.Method, "http://"+r.Host+r.RequestURI, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signRequest(req, body, creds, "eu-central-1", service, now)
+		if want, have := req.Header.Get("Authorization"), r.Header.Get("Authorization"); want != have {
+			t.Errorf("Authorization: want\n%s\nhave\n%s", want, have)
+		}
+		if want, have := "token", r.Header.Get("X-Amz-Security-Token"); want != have {
+			t.Errorf("X-Amz-Security-Token: want %q, have %q", want, have)
+		}
+		if want, have := `{"query":{"match_all":{}}}`, string(body); r.Method == "POST" && want != have {
+			t.Errorf("body: want %q, have %q", want, have)
+		}
+		hasContentHash := r.Header.Get("X-Amz-Content-Sha256") != ""
+		if want, have := service == sigV4ServerlessService, hasContentHash; want != have {
+			t.Errorf("X-Amz-Content-Sha256: want %v, have %v", want, have)
+		}
+	}
+
+	for _, service := range []string{sigV4DefaultService, sigV4ServerlessService} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verify(t, r, service)
+		}))
+
+		tr := &signingTransport{
+			next:    http.DefaultTransport,
+			creds:   creds,
+			region:  "eu-central-1",
+			service: service,
+			now:     time.Now,
+		}
+		c := &http.Client{Transport: tr}
+
+		res, err := c.Get(ts.URL + "/index01/_doc/a%2Fb?routing=x&pretty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		res, err = c.Post(ts.URL+"/index01/_search?scroll=5m&size=100", "application/json", strings.NewReader(`{"query":{"match_all":{}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		ts.Close()
+	}
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+	writeFile(t, credentialsFile, `
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[prod]
+aws_access_key_id=AKIDPROD
+aws_secret_access_key=prod-secret
+aws_session_token=prod-token
+`)
+	writeFile(t, configFile, `
+[default]
+region = us-east-1
+
+[profile prod]
+region = eu-west-1
+`)
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+
+	tests := []struct {
+		Profile string
+		Creds   AWSCredentials
+		Region  string
+	}{
+		{Profile: "", Creds: AWSCredentials{AccessKeyID: "AKIDDEFAULT", SecretAccessKey: "default-secret"}, Region: "us-east-1"},
+		{Profile: "prod", Creds: AWSCredentials{AccessKeyID: "AKIDPROD", SecretAccessKey: "prod-secret", SessionToken: "prod-token"}, Region: "eu-west-1"},
+	}
+	for i, tt := range tests {
+		creds, err := LoadAWSCredentials(tt.Profile)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Creds, creds; want != have {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+		region, err := LoadAWSRegion(tt.Profile)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want, have := tt.Region, region; want != have {
+			t.Errorf("#%d: want region %q, have %q", i, want, have)
+		}
+	}
+
+	if _, err := LoadAWSCredentials("missing"); err == nil {
+		t.Error("want error for missing profile, have nil")
+	}
+
+	// Environment variables take precedence
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_REGION", "ap-south-1")
+	tr, err := New(&config.Config{AWSSigV4: true, AWSProfile: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := tr.(*signingTransport)
+	if !ok {
+		t.Fatalf("want *signingTransport, have %T", tr)
+	}
+	if want, have := "AKIDENV", st.creds.AccessKeyID; want != have {
+		t.Errorf("want access key %q, have %q", want, have)
+	}
+	if want, have := "ap-south-1", st.region; want != have {
+		t.Errorf("want region %q, have %q", want, have)
+	}
+	if want, have := sigV4DefaultService, st.service; want != have {
+		t.Errorf("want service %q, have %q", want, have)
+	}
+}
+
+func writeFile(t *testing.T, filename, data string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package transport creates the HTTP transport for talking to a cluster
+// as specified in a configuration, e.g. with custom certificate
+// authorities, client certificates, API keys, or AWS request signing.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/elastic/config"
+)
+
+// New returns a new HTTP transport for the configuration.
+func New(cfg *config.Config) (http.RoundTripper, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg == nil {
+		return tr, nil
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tr.TLSClientConfig = tlsConfig
+	}
+	var rt http.RoundTripper = tr
+	if cfg.AWSSigV4 || cfg.AWSRegion != "" {
+		if rt, err = newSigningTransport(cfg, rt); err != nil {
+			return nil, err
+		}
+	}
+	if header := authHeader(cfg); len(header) > 0 {
+		rt = &headerTransport{next: rt, header: header}
+	}
+	return rt, nil
+}
+
+// newSigningTransport returns a transport that signs requests with
+// AWS Signature Version 4 before passing them to next.
+func newSigningTransport(cfg *config.Config, next http.RoundTripper) (http.RoundTripper, error) {
+	creds, err := LoadAWSCredentials(cfg.AWSProfile)
+	if err != nil {
+		return nil, err
+	}
+	region := cfg.AWSRegion
+	if region == "" {
+		if region, err = LoadAWSRegion(cfg.AWSProfile); err != nil {
+			return nil, err
+		}
+	}
+	service := cfg.AWSService
+	if service == "" {
+		service = sigV4DefaultService
+	}
+	return &signingTransport{
+		next:    next,
+		creds:   creds,
+		region:  region,
+		service: service,
+		now:     time.Now,
+	}, nil
+}
+
+// NewClient returns a new HTTP client that uses the transport
+// returned by New.
+func NewClient(cfg *config.Config) (*http.Client, error) {
+	tr, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr}, nil
+}
+
+// authHeader returns the headers to add to every request for cfg.
+// An API key or bearer token replace the Authorization header of basic
+// authentication.
+func authHeader(cfg *config.Config) http.Header {
+	header := cfg.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	switch {
+	case cfg.APIKey != "":
+		apiKey := cfg.APIKey
+		if strings.Contains(apiKey, ":") {
+			// Encode "id:api_key"
+			apiKey = base64.StdEncoding.EncodeToString([]byte(apiKey))
+		}
+		header.Set("Authorization", "ApiKey "+apiKey)
+	case cfg.BearerToken != "":
+		header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	}
+	return header
+}
+
+// headerTransport adds headers to every request.
+type headerTransport struct {
+	next   http.RoundTripper
+	header http.Header
+}
+
+// RoundTrip adds the headers to a copy of req and passes it on.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
+
+// newTLSConfig returns the TLS configuration for cfg, or nil if cfg
+// doesn't change the defaults.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.CACert == "" && cfg.Cert == "" && cfg.Key == "" && !cfg.Insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.Insecure,
+	}
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read CA certificates")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no CA certificates found in %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Cert != "" || cfg.Key != "" {
+		if cfg.Cert == "" || cfg.Key == "" {
+			return nil, errors.New("client certificate and key must be specified together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olivere/esdiff/elastic/config"
+)
+
+func TestTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "missing client certificate", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// Write the server certificate and key, which we use as CA
+	// certificate and client certificate as well
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", ts.Certificate().Raw)
+	key, err := x509.MarshalPKCS8PrivateKey(ts.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, keyFile, "PRIVATE KEY", key)
+
+	tests := []struct {
+		Config     *config.Config
+		StatusCode int
+		Err        bool
+	}{
+		// #0 Unknown certificate authority
+		{Config: &config.Config{}, Err: true},
+		// #1 Insecure
+		{Config: &config.Config{Insecure: true}, StatusCode: http.StatusUnauthorized},
+		// #2 Custom certificate authority
+		{Config: &config.Config{CACert: certFile}, StatusCode: http.StatusUnauthorized},
+		// #3 Client certificate
+		{Config: &config.Config{CACert: certFile, Cert: certFile, Key: keyFile}, StatusCode: http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		c, err := NewClient(tt.Config)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		res, err := c.Get(ts.URL)
+		if tt.Err {
+			if err == nil {
+				t.Fatalf("#%d: want error, have nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		res.Body.Close()
+		if want, have := tt.StatusCode, res.StatusCode; want != have {
+			t.Fatalf("#%d: want status %d, have %d", i, want, have)
+		}
+	}
+}
+
+func TestTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []*config.Config{
+		{CACert: filepath.Join(dir, "missing.pem")},
+		{CACert: invalidFile},
+		{Cert: invalidFile},
+		{Cert: invalidFile, Key: invalidFile},
+	}
+	for i, cfg := range tests {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("#%d: want error, have nil", i)
+		}
+	}
+}
+
+func writePEM(t *testing.T, filename, typ string, data []byte) {
+	t.Helper()
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: typ, Bytes: data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		Config        *config.Config
+		Authorization string
+		Tenant        string
+	}{
+		// #0
+		{Config: &config.Config{}},
+		// #1
+		{Config: &config.Config{APIKey: "VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="}, Authorization: "ApiKey VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="},
+		// #2
+		{Config: &config.Config{APIKey: "VuaCfGcBCdbkQm-e5aOx:ui2lp2axTNmsyakw9tvNnw"}, Authorization: "ApiKey VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="},
+		// #3
+		{Config: &config.Config{BearerToken: "secret"}, Authorization: "Bearer secret"},
+		// #4
+		{Config: &config.Config{Headers: http.Header{"X-Tenant": {"acme"}}}, Tenant: "acme"},
+	}
+
+	for i, tt := range tests {
+		c, err := NewClient(tt.Config)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("elastic", "changeme")
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		res.Body.Close()
+		if tt.Authorization == "" {
+			// Basic authentication
+			tt.Authorization = req.Header.Get("Authorization")
+		}
+		if want, have := tt.Authorization, header.Get("Authorization"); want != have {
+			t.Errorf("#%d: Authorization: want %q, have %q", i, want, have)
+		}
+		if want, have := tt.Tenant, header.Get("X-Tenant"); want != have {
+			t.Errorf("#%d: X-Tenant: want %q, have %q", i, want, have)
+		}
+	}
+}
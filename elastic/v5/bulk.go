@@ -0,0 +1,48 @@
+package v5
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	elasticv5 "gopkg.in/olivere/elastic.v5"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Bulk sends the actions to the index in a single request to the Bulk API.
+func (c *Client) Bulk(ctx context.Context, req *elastic.BulkRequest) (*elastic.BulkResponse, error) {
+	if c.typ == "" {
+		// Documents of Elasticsearch 5.x can't be written without a type
+		return nil, errors.Errorf("writing to index %s requires a type, e.g. %s/tweet", c.index, c.index)
+	}
+	svc := c.c.Bulk().Index(c.index).Type(c.typ)
+	if req.Refresh != "" {
+		svc = svc.Refresh(req.Refresh)
+	}
+	for _, action := range req.Actions {
+		switch action.Op {
+		case diff.BulkIndex:
+			svc = svc.Add(elasticv5.NewBulkIndexRequest().Index(action.Index).Id(action.ID).Routing(action.Routing).Doc(action.Source))
+		case diff.BulkDelete:
+			svc = svc.Add(elasticv5.NewBulkDeleteRequest().Index(action.Index).Id(action.ID).Routing(action.Routing))
+		default:
+			return nil, errors.Errorf("unknown bulk operation %q", action.Op)
+		}
+	}
+	res, err := svc.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := &elastic.BulkResponse{}
+	for _, item := range res.Items {
+		for op, v := range item {
+			ri := elastic.BulkResponseItem{ID: v.Id, Status: v.Status}
+			if v.Error != nil {
+				ri.Error = &elastic.BulkError{Type: v.Error.Type, Reason: v.Error.Reason}
+			}
+			out.Add(diff.BulkOp(op), ri)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,21 @@
+package v5
+
+import (
+	"context"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Mapping returns the mapping of the index for the type of the client,
+// or for the only type of the index if the client has no type.
+func (c *Client) Mapping(ctx context.Context) (map[string]interface{}, error) {
+	svc := c.c.GetMapping().Index(c.index)
+	if c.typ != "" {
+		svc = svc.Type(c.typ)
+	}
+	res, err := svc.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return elastic.UnwrapMapping(res, c.typ)
+}
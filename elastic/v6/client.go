@@ -6,7 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"time"
 
 	elasticv6 "github.com/olivere/elastic"
 	"github.com/pkg/errors"
@@ -14,6 +14,17 @@ import (
 	"github.com/olivere/esdiff/diff"
 	"github.com/olivere/esdiff/elastic"
 	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/transport"
+)
+
+const (
+	// keepAlive is the time a scroll context is kept open
+	// between two requests.
+	keepAlive = "5m"
+
+	// cleanupTimeout is the time we allow for clearing a scroll
+	// context after iterating.
+	cleanupTimeout = 10 * time.Second
 )
 
 // Client implements an Elasticsearch 6.x client.
@@ -58,6 +69,11 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		if cfg.Username != "" || cfg.Password != "" {
 			options = append(options, elasticv6.SetBasicAuth(cfg.Username, cfg.Password))
 		}
+		httpClient, err := transport.NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, elasticv6.SetHttpClient(httpClient))
 		options = append(options, elasticv6.SetSniff(cfg.Sniff))
 	}
 	cli, err := elasticv6.NewClient(options...)
@@ -89,85 +105,136 @@ func (c *Client) Iterate(ctx context.Context, req *elastic.IterateRequest) (<-ch
 			close(errCh)
 		}()
 
-		// Sorting
-		var sorter elasticv6.Sorter
-		if req.SortField == "" {
-			sorter = elasticv6.NewFieldSort("_id").Asc()
-		} else {
-			field := req.SortField
-			asc := true
-			if field[0] == '-' {
-				field = field[1:]
-				asc = false
-			}
-			sorter = elasticv6.NewFieldSort(field).Order(asc)
+		var err error
+		switch req.Strategy {
+		case elastic.StrategyAuto, elastic.StrategyScroll:
+			err = c.scroll(ctx, req, docCh)
+		case elastic.StrategyPointInTime:
+			err = errors.New("point in time is not supported by Elasticsearch 6.x")
+		default:
+			err = errors.Errorf("unsupported iterate strategy %q", req.Strategy)
+		}
+		if err != nil {
+			errCh <- err
 		}
+	}()
+
+	return docCh, errCh
+}
 
-		svc := c.c.Scroll(c.index).Type(c.typ).Size(c.size).SortBy(sorter)
-		if req.RawQuery != "" {
-			q := elasticv6.NewRawStringQuery(req.RawQuery)
-			svc = svc.Query(q)
+// scroll iterates over the index with the Scroll API, reading
+// req.Slices slices in parallel if requested.
+func (c *Client) scroll(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	if req.Slices <= 1 {
+		return c.scrollSlice(ctx, req, nil, docCh)
+	}
+	return elastic.IterateSlices(ctx, req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		q := elasticv6.NewSliceQuery().Id(slice).Max(req.Slices)
+		return c.scrollSlice(ctx, req, q, docCh)
+	})
+}
+
+// scrollSlice iterates over a single slice of the index with the
+// Scroll API, or over the whole index if slice is nil. It clears
+// the scroll context when done.
+func (c *Client) scrollSlice(ctx context.Context, req *elastic.IterateRequest, slice elasticv6.Query, docCh chan<- *diff.Document) error {
+	svc := c.c.Scroll(c.index).Type(c.typ).Size(c.size).KeepAlive(keepAlive).SortBy(sorters(req)...)
+
+	if slice != nil {
+		svc = svc.Slice(slice)
+	}
+
+	if req.RawQuery != "" {
+		q := elasticv6.NewRawStringQuery(req.RawQuery)
+		svc = svc.Query(q)
+	}
+
+	if fsc := fetchSourceContext(req); fsc != nil {
+		svc = svc.FetchSourceContext(fsc)
+	}
+
+	defer func() {
+		// Use a separate context as ctx might have been canceled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		_ = svc.Clear(cleanupCtx)
+	}()
+
+	for {
+		res, err := svc.Do(ctx)
+		if err == io.EOF {
+			return nil
 		}
-		if len(req.SourceFilterInclude)+len(req.SourceFilterExclude) > 0 {
-			fsc := elasticv6.NewFetchSourceContext(true).
-				Include(req.SourceFilterInclude...).
-				Exclude(req.SourceFilterExclude...)
-			svc = svc.FetchSourceContext(fsc)
+		if err != nil {
+			return err
 		}
-		for {
-			res, err := svc.Do(ctx)
-			if err == io.EOF {
-				return
-			}
+		if res == nil {
+			return errors.New("unexpected nil document")
+		}
+
+		if res.Hits == nil {
+			return errors.New("unexpected nil hits")
+		}
+
+		for _, hit := range res.Hits.Hits {
+			doc, err := newDocument(req, hit)
 			if err != nil {
-				errCh <- err
-				return
-			}
-			if res == nil {
-				errCh <- errors.New("unexpected nil document")
-				return
+				return err
 			}
-			if res.Hits == nil {
-				errCh <- errors.New("unexpected nil hits")
-				return
+			if doc == nil {
+				// Skipped as it has no key
+				continue
 			}
-			for _, hit := range res.Hits.Hits {
-				doc := new(diff.Document)
-				err := json.Unmarshal(*hit.Source, &doc.Source)
-				if err != nil {
-					errCh <- err
-					return
-				}
-				// Replace ID field with some other field from the document?
-				if req.ReplaceField != "" {
-					if val, ok := doc.Source[req.ReplaceField]; ok {
-						switch v := val.(type) {
-						case string:
-							doc.ID = v
-						case int:
-							doc.ID = strconv.Itoa(v)
-						case int32:
-							doc.ID = strconv.FormatInt(int64(v), 10)
-						case int64:
-							doc.ID = strconv.FormatInt(v, 10)
-						case float32:
-							doc.ID = strconv.Itoa(int(v))
-						case float64:
-							doc.ID = strconv.Itoa(int(v))
-						default:
-							doc.ID = val.(string)
-						}
-					} else {
-						errCh <- errors.New("unexpected replace-with field")
-						return
-					}
-				} else {
-					doc.ID = hit.Id
-				}
-				docCh <- doc
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
-	}()
+	}
+}
 
-	return docCh, errCh
+// sorters returns the sort order for the request, which defaults
+// to index order for unordered requests, and to sorting by the
+// fields of the key or by _id.
+func sorters(req *elastic.IterateRequest) []elasticv6.Sorter {
+	if field := elastic.UnorderedSort(req, false); field != "" {
+		return []elasticv6.Sorter{elasticv6.NewFieldSort(field).Asc()}
+	}
+	sorts := elastic.Sorts(req)
+	if len(sorts) == 0 {
+		return []elasticv6.Sorter{elasticv6.NewFieldSort("_id").Asc()}
+	}
+	sorters := make([]elasticv6.Sorter, len(sorts))
+	for i, sort := range sorts {
+		sorters[i] = elasticv6.NewFieldSort(sort.Field).Order(sort.Asc)
+	}
+	return sorters
+}
+
+// fetchSourceContext returns the source filter for the request,
+// or nil if there is none.
+func fetchSourceContext(req *elastic.IterateRequest) *elasticv6.FetchSourceContext {
+	if len(req.SourceFilterInclude)+len(req.SourceFilterExclude) == 0 {
+		return nil
+	}
+	return elasticv6.NewFetchSourceContext(true).
+		Include(req.SourceFilterInclude...).
+		Exclude(req.SourceFilterExclude...)
+}
+
+// newDocument creates a document from a search hit. It returns nil
+// if the document is skipped because it has no key.
+func newDocument(req *elastic.IterateRequest, hit *elasticv6.SearchHit) (*diff.Document, error) {
+	doc := new(diff.Document)
+	err := json.Unmarshal(*hit.Source, &doc.Source)
+	if err != nil {
+		return nil, err
+	}
+	// Replace ID field with some other field from the document?
+	meta := elastic.DocumentMeta{ID: hit.Id, Index: hit.Index, Routing: hit.Routing, Source: *hit.Source}
+	if ok, err := elastic.SetDocumentID(req, doc, meta); !ok || err != nil {
+		return nil, err
+	}
+	return doc, nil
 }
@@ -0,0 +1,39 @@
+package v6
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	elasticv6 "github.com/olivere/elastic"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// IndexSettings returns the settings and aliases of the index, and the
+// index templates of the cluster.
+func (c *Client) IndexSettings(ctx context.Context) (*elastic.IndexSettings, error) {
+	return elastic.LoadIndexSettings(ctx, c.index, c.get)
+}
+
+// get performs a GET request for path and decodes the response into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	res, err := c.c.PerformRequest(ctx, elasticv6.PerformRequestOptions{
+		Method:       "GET",
+		Path:         path,
+		IgnoreErrors: []int{http.StatusNotFound, http.StatusBadRequest, http.StatusMethodNotAllowed},
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode >= 400 {
+		// Bad Request and Method Not Allowed are ignored above to
+		// return them as a StatusError, see elastic.LoadIndexSettings
+		return false, elastic.StatusError{StatusCode: res.StatusCode, Body: string(bytes.TrimSpace(res.Body))}
+	}
+	return true, json.Unmarshal(res.Body, v)
+}
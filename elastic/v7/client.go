@@ -6,19 +6,32 @@ import (
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"time"
 
+	"github.com/Masterminds/semver"
 	elastic7 "github.com/olivere/elastic/v7"
 	"github.com/pkg/errors"
 
 	"github.com/olivere/esdiff/diff"
 	"github.com/olivere/esdiff/elastic"
 	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/transport"
+)
+
+const (
+	// keepAlive is the time a scroll context or point in time
+	// is kept open between two requests.
+	keepAlive = "5m"
+
+	// cleanupTimeout is the time we allow for clearing a scroll
+	// context or closing a point in time after iterating.
+	cleanupTimeout = 10 * time.Second
 )
 
 // Client implements an Elasticsearch 7.x client.
 type Client struct {
 	c     *elastic7.Client
+	url   string
 	index string
 	typ   string
 	size  int
@@ -58,6 +71,11 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		if cfg.Username != "" || cfg.Password != "" {
 			options = append(options, elastic7.SetBasicAuth(cfg.Username, cfg.Password))
 		}
+		httpClient, err := transport.NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, elastic7.SetHttpClient(httpClient))
 		options = append(options, elastic7.SetSniff(cfg.Sniff))
 	}
 	cli, err := elastic7.NewClient(options...)
@@ -66,10 +84,14 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	}
 	c := &Client{
 		c:     cli,
+		url:   elastic7.DefaultURL,
 		index: cfg.Index,
 		typ:   cfg.Type,
 		size:  100,
 	}
+	if cfg.URL != "" {
+		c.url = cfg.URL
+	}
 	return c, nil
 }
 
@@ -89,91 +111,266 @@ func (c *Client) Iterate(ctx context.Context, req *elastic.IterateRequest) (<-ch
 			close(errCh)
 		}()
 
-		// Sorting
-		var sorter elastic7.Sorter
-		if req.SortField == "" {
-			sorter = elastic7.NewFieldSort("_id").Asc()
-		} else {
-			field := req.SortField
-			asc := true
-			if field[0] == '-' {
-				field = field[1:]
-				asc = false
+		strategy := req.Strategy
+		if strategy == elastic.StrategyAuto {
+			ok, err := c.supportsPointInTime(req)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if ok {
+				strategy = elastic.StrategyPointInTime
+			} else {
+				strategy = elastic.StrategyScroll
 			}
-			sorter = elastic7.NewFieldSort(field).Order(asc)
 		}
 
-		svc := c.c.Scroll(c.index).Type(c.typ).Size(c.size).SortBy(sorter)
+		var err error
+		switch strategy {
+		case elastic.StrategyScroll:
+			err = c.scroll(ctx, req, docCh)
+		case elastic.StrategyPointInTime:
+			err = c.searchAfter(ctx, req, docCh)
+		default:
+			err = errors.Errorf("unsupported iterate strategy %q", strategy)
+		}
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return docCh, errCh
+}
 
-		if req.RawQuery != "" {
-			q := elastic7.NewRawStringQuery(req.RawQuery)
-			svc = svc.Query(q)
+// supportsPointInTime returns true if the cluster supports
+// point in time, which was added in Elasticsearch 7.10, for req.
+// Unordered requests sort by _shard_doc, which was added in 7.12.
+func (c *Client) supportsPointInTime(req *elastic.IterateRequest) (bool, error) {
+	number, err := c.c.ElasticsearchVersion(c.url)
+	if err != nil {
+		return false, err
+	}
+	v, err := semver.NewVersion(number)
+	if err != nil {
+		return false, err
+	}
+	minor := int64(10)
+	if elastic.UnorderedSort(req, true) != "" {
+		minor = 12
+	}
+	return v.Major() > 7 || (v.Major() == 7 && v.Minor() >= minor), nil
+}
+
+// scroll iterates over the index with the Scroll API, reading
+// req.Slices slices in parallel if requested.
+func (c *Client) scroll(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	if req.Slices <= 1 {
+		return c.scrollSlice(ctx, req, nil, docCh)
+	}
+	return elastic.IterateSlices(ctx, req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		q := elastic7.NewSliceQuery().Id(slice).Max(req.Slices)
+		return c.scrollSlice(ctx, req, q, docCh)
+	})
+}
+
+// scrollSlice iterates over a single slice of the index with the
+// Scroll API, or over the whole index if slice is nil. It clears
+// the scroll context when done.
+func (c *Client) scrollSlice(ctx context.Context, req *elastic.IterateRequest, slice elastic7.Query, docCh chan<- *diff.Document) error {
+	svc := c.c.Scroll(c.index).Type(c.typ).Size(c.size).KeepAlive(keepAlive).SortBy(sorters(req, false)...)
+
+	if slice != nil {
+		svc = svc.Slice(slice)
+	}
+
+	if req.RawQuery != "" {
+		q := elastic7.NewRawStringQuery(req.RawQuery)
+		svc = svc.Query(q)
+	}
+
+	if fsc := fetchSourceContext(req); fsc != nil {
+		svc = svc.FetchSourceContext(fsc)
+	}
+
+	defer func() {
+		// Use a separate context as ctx might have been canceled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		_ = svc.Clear(cleanupCtx)
+	}()
+
+	for {
+		res, err := svc.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if res == nil {
+			return errors.New("unexpected nil document")
 		}
 
-		if len(req.SourceFilterInclude)+len(req.SourceFilterExclude) > 0 {
-			fsc := elastic7.NewFetchSourceContext(true).
-				Include(req.SourceFilterInclude...).
-				Exclude(req.SourceFilterExclude...)
-			svc = svc.FetchSourceContext(fsc)
+		if res.Hits == nil {
+			return errors.New("unexpected nil hits")
 		}
 
-		for {
-			res, err := svc.Do(ctx)
-			if err == io.EOF {
-				return
-			}
+		for _, hit := range res.Hits.Hits {
+			doc, err := newDocument(req, hit)
 			if err != nil {
-				errCh <- err
-				return
+				return err
 			}
-			if res == nil {
-				errCh <- errors.New("unexpected nil document")
-				return
+			if doc == nil {
+				// Skipped as it has no key
+				continue
 			}
-
-			if res.Hits == nil {
-				errCh <- errors.New("unexpected nil hits")
-				return
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
+		}
+	}
+}
 
-			for _, hit := range res.Hits.Hits {
-				doc := new(diff.Document)
-				err := json.Unmarshal(hit.Source, &doc.Source)
-				if err != nil {
-					errCh <- err
-					return
-				}
-				// Replace ID field with some other field from the document?
-				if req.ReplaceField != "" {
-					if val, ok := doc.Source[req.ReplaceField]; ok {
-						switch v := val.(type) {
-						case string:
-							doc.ID = v
-						case int:
-							doc.ID = strconv.Itoa(v)
-						case int32:
-							doc.ID = strconv.FormatInt(int64(v), 10)
-						case int64:
-							doc.ID = strconv.FormatInt(v, 10)
-						case float32:
-							doc.ID = strconv.Itoa(int(v))
-						case float64:
-							doc.ID = strconv.Itoa(int(v))
-						default:
-							doc.ID = val.(string)
-						}
-					} else {
-						errCh <- errors.New("unexpected replace-with field")
-						return
-					}
-				} else {
-					doc.ID = hit.Id
-				}
-				docCh <- doc
+// searchAfter iterates over the index with a point in time and
+// search_after, reading req.Slices slices in parallel if requested.
+// It closes the point in time when done.
+func (c *Client) searchAfter(ctx context.Context, req *elastic.IterateRequest, docCh chan<- *diff.Document) error {
+	pit, err := c.c.OpenPointInTime(c.index).KeepAlive(keepAlive).Do(ctx)
+	if err != nil {
+		return err
+	}
+	pitID := pit.Id
+
+	defer func() {
+		// Use a separate context as ctx might have been canceled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		_, _ = c.c.ClosePointInTime(pitID).Do(cleanupCtx)
+	}()
+
+	if req.Slices <= 1 {
+		return c.searchAfterSlice(ctx, req, pitID, nil, docCh)
+	}
+	return elastic.IterateSlices(ctx, req, docCh, func(ctx context.Context, slice int, docCh chan<- *diff.Document) error {
+		q := elastic7.NewSliceQuery().Id(slice).Max(req.Slices)
+		return c.searchAfterSlice(ctx, req, pitID, q, docCh)
+	})
+}
+
+// searchAfterSlice iterates over a single slice of the point in time
+// with search_after, or over all of it if slice is nil.
+func (c *Client) searchAfterSlice(ctx context.Context, req *elastic.IterateRequest, pitID string, slice elastic7.Query, docCh chan<- *diff.Document) error {
+	// Sort by _id as a tiebreaker so that search_after doesn't
+	// skip documents with the same value in the sort field
+	sorts := sorters(req, true)
+	if elastic.UnorderedSort(req, true) == "" && elastic.NeedsTiebreaker(req) {
+		sorts = append(sorts, elastic7.NewFieldSort("_id").Asc())
+	}
+
+	var searchAfter []interface{}
+	for {
+		source := elastic7.NewSearchSource().
+			Size(c.size).
+			SortBy(sorts...).
+			PointInTime(elastic7.NewPointInTimeWithKeepAlive(pitID, keepAlive))
+
+		if req.RawQuery != "" {
+			source = source.Query(elastic7.NewRawStringQuery(req.RawQuery))
+		}
+
+		if fsc := fetchSourceContext(req); fsc != nil {
+			source = source.FetchSourceContext(fsc)
+		}
+
+		if slice != nil {
+			source = source.Slice(slice)
+		}
+
+		if len(searchAfter) > 0 {
+			source = source.SearchAfter(searchAfter...)
+		}
+
+		res, err := c.c.Search().SearchSource(source).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if res == nil {
+			return errors.New("unexpected nil document")
+		}
+		if res.PitId != "" {
+			pitID = res.PitId
+		}
 
+		if res.Hits == nil {
+			return errors.New("unexpected nil hits")
+		}
+		if len(res.Hits.Hits) == 0 {
+			return nil
+		}
+
+		for _, hit := range res.Hits.Hits {
+			doc, err := newDocument(req, hit)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				// Skipped as it has no key
+				continue
+			}
+			select {
+			case docCh <- doc:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
-	}()
 
-	return docCh, errCh
+		searchAfter = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+	}
+}
+
+// sorters returns the sort order for the request, which defaults
+// to index order for unordered requests, and to sorting by the
+// fields of the key or by _id.
+func sorters(req *elastic.IterateRequest, pointInTime bool) []elastic7.Sorter {
+	if field := elastic.UnorderedSort(req, pointInTime); field != "" {
+		return []elastic7.Sorter{elastic7.NewFieldSort(field).Asc()}
+	}
+	sorts := elastic.Sorts(req)
+	if len(sorts) == 0 {
+		return []elastic7.Sorter{elastic7.NewFieldSort("_id").Asc()}
+	}
+	sorters := make([]elastic7.Sorter, len(sorts))
+	for i, sort := range sorts {
+		sorters[i] = elastic7.NewFieldSort(sort.Field).Order(sort.Asc)
+	}
+	return sorters
+}
+
+// fetchSourceContext returns the source filter for the request,
+// or nil if there is none.
+func fetchSourceContext(req *elastic.IterateRequest) *elastic7.FetchSourceContext {
+	if len(req.SourceFilterInclude)+len(req.SourceFilterExclude) == 0 {
+		return nil
+	}
+	return elastic7.NewFetchSourceContext(true).
+		Include(req.SourceFilterInclude...).
+		Exclude(req.SourceFilterExclude...)
+}
+
+// newDocument creates a document from a search hit. It returns nil
+// if the document is skipped because it has no key.
+func newDocument(req *elastic.IterateRequest, hit *elastic7.SearchHit) (*diff.Document, error) {
+	doc := new(diff.Document)
+	err := json.Unmarshal(hit.Source, &doc.Source)
+	if err != nil {
+		return nil, err
+	}
+	// Replace ID field with some other field from the document?
+	meta := elastic.DocumentMeta{ID: hit.Id, Index: hit.Index, Routing: hit.Routing, Source: hit.Source}
+	if ok, err := elastic.SetDocumentID(req, doc, meta); !ok || err != nil {
+		return nil, err
+	}
+	return doc, nil
 }
@@ -0,0 +1,50 @@
+package v7
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	elastic7 "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Mapping returns the mapping of the index, which is no longer nested
+// below a type in Elasticsearch 7.x.
+func (c *Client) Mapping(ctx context.Context) (map[string]interface{}, error) {
+	// GetMapping of the client asks for the deprecated _all type
+	var res map[string]interface{}
+	found, err := c.get(ctx, "/"+url.PathEscape(c.index)+"/_mapping", &res)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("index %s not found", c.index)
+	}
+	return elastic.UnwrapMapping(res, "")
+}
+
+// get performs a GET request for path and decodes the response into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) (bool, error) {
+	res, err := c.c.PerformRequest(ctx, elastic7.PerformRequestOptions{
+		Method:       "GET",
+		Path:         path,
+		IgnoreErrors: []int{http.StatusNotFound, http.StatusBadRequest, http.StatusMethodNotAllowed},
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode >= 400 {
+		// Bad Request and Method Not Allowed are ignored above to
+		// return them as a StatusError, see elastic.LoadIndexSettings
+		return false, elastic.StatusError{StatusCode: res.StatusCode, Body: string(bytes.TrimSpace(res.Body))}
+	}
+	return true, json.Unmarshal(res.Body, v)
+}
@@ -0,0 +1,13 @@
+package v7
+
+import (
+	"context"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// IndexSettings returns the settings and aliases of the index, and the
+// index templates of the cluster.
+func (c *Client) IndexSettings(ctx context.Context) (*elastic.IndexSettings, error) {
+	return elastic.LoadIndexSettings(ctx, c.index, c.get)
+}
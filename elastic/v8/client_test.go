@@ -0,0 +1,114 @@
+package v8
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/config"
+)
+
+func TestIterateSortByID(t *testing.T) {
+	tests := []struct {
+		Req      elastic.IterateRequest
+		Settings string // response of the cluster settings, or empty for 403
+		Sort     string // field to sort by, or empty for an error
+	}{
+		{
+			Req:      elastic.IterateRequest{},
+			Settings: `{"persistent":{},"transient":{},"defaults":{"indices.id_field_data.enabled":"false"}}`,
+		},
+		{
+			Req:      elastic.IterateRequest{},
+			Settings: `{"persistent":{"indices.id_field_data.enabled":"true"},"transient":{},"defaults":{"indices.id_field_data.enabled":"false"}}`,
+			Sort:     "_id",
+		},
+		{
+			Req:      elastic.IterateRequest{},
+			Settings: ``,
+			Sort:     "_id",
+		},
+		{
+			Req:      elastic.IterateRequest{Unordered: true},
+			Settings: `{"persistent":{},"transient":{},"defaults":{"indices.id_field_data.enabled":"false"}}`,
+			Sort:     "_doc",
+		},
+		{
+			Req:      elastic.IterateRequest{SortField: "id"},
+			Settings: `{"persistent":{},"transient":{},"defaults":{"indices.id_field_data.enabled":"false"}}`,
+			Sort:     "id",
+		},
+	}
+	for i, tt := range tests {
+		var mu sync.Mutex
+		var sorts []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/_cluster/settings":
+				if tt.Settings == "" {
+					http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+					return
+				}
+				w.Write([]byte(tt.Settings))
+			case "/index01/_search":
+				var body struct {
+					Sort []map[string]interface{} `json:"sort"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				mu.Lock()
+				for _, sort := range body.Sort {
+					for field := range sort {
+						sorts = append(sorts, field)
+					}
+				}
+				mu.Unlock()
+				w.Write([]byte(`{"_scroll_id":"1","hits":{"hits":[]}}`))
+			default:
+				w.Write([]byte(`{}`))
+			}
+		}))
+		defer ts.Close()
+
+		cfg, err := config.Parse(ts.URL + "/index01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewClient(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := tt.Req
+		req.Strategy = elastic.StrategyScroll
+		docCh, errCh := c.Iterate(context.Background(), &req)
+		for range docCh {
+		}
+		err = <-errCh
+		if tt.Sort == "" {
+			if err == nil || !strings.Contains(err.Error(), "indices.id_field_data.enabled") {
+				t.Errorf("#%d: want error about the cluster setting, have %v", i, err)
+			}
+			if len(sorts) > 0 {
+				t.Errorf("#%d: want no search, have %v", i, sorts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if want := []string{tt.Sort}; !cmp.Equal(want, sorts) {
+			t.Errorf("#%d: %v", i, cmp.Diff(want, sorts))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package v8
+
+import (
+	"context"
+
+	"github.com/olivere/esdiff/elastic"
+)
+
+// Mapping returns the mapping of the index.
+func (c *Client) Mapping(ctx context.Context) (map[string]interface{}, error) {
+	res, err := c.c.Indices.GetMapping(
+		c.c.Indices.GetMapping.WithContext(ctx),
+		c.c.Indices.GetMapping.WithIndex(c.index),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]interface{}
+	if err := decodeResponse(res, &mapping); err != nil {
+		return nil, err
+	}
+	return elastic.UnwrapMapping(mapping, "")
+}
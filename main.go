@@ -10,6 +10,8 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/pkg/errors"
@@ -19,14 +21,48 @@ import (
 	"github.com/olivere/esdiff/diff/printer"
 	"github.com/olivere/esdiff/elastic"
 	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/file"
+	"github.com/olivere/esdiff/elastic/opensearch"
+	"github.com/olivere/esdiff/elastic/transport"
 	v5 "github.com/olivere/esdiff/elastic/v5"
 	v6 "github.com/olivere/esdiff/elastic/v6"
 	v7 "github.com/olivere/esdiff/elastic/v7"
+	v8 "github.com/olivere/esdiff/elastic/v8"
+)
+
+// Exit codes, similar to diff(1).
+const (
+	// exitIdentical means that no differences were found.
+	exitIdentical = 0
+	// exitDifferent means that differences were found, or that
+	// differences exceeded one of the thresholds.
+	exitDifferent = 1
+	// exitError means that an error occurred.
+	exitError = 2
 )
 
 func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dump":
+			runDump(os.Args[2:])
+			return
+		case "mapping":
+			runMapping(os.Args[2:])
+			return
+		case "settings":
+			runSettings(os.Args[2:])
+			return
+		case "sync":
+			runSync(os.Args[2:])
+			return
+		}
+	}
+
 	var (
-		outputFormat            = flag.String("o", "", "Output format, e.g. json")
+		outputFormat            = flag.String("o", "", "Output format, e.g. json, jsonpatch or bulk")
 		size                    = flag.Int("size", 100, "Batch size")
 		rawSrcQuery             = flag.String("sf", "", `Raw query for filtering the source, e.g. {"term":{"user":"olivere"}}`)
 		rawDstQuery             = flag.String("df", "", `Raw query for filtering the destination, e.g. {"term":{"name.keyword":"Oliver"}}`)
@@ -38,16 +74,29 @@ func main() {
 		updated                 = flag.Bool("c", true, `Print changed docs`)
 		changed                 = flag.Bool("a", true, `Print added docs`)
 		deleted                 = flag.Bool("d", true, `Print deleted docs`)
-		replaceWithAnotherField = flag.String("replace-with", "", `replace id field to other field you want`)
+		replaceWithAnotherField = flag.String("replace-with", "", `Replace the ID with the value of other fields, e.g. "sku", "order.id" or "tenant_id,order_id" (may include _id, _index and _routing)`)
+		matchBy                 = flag.String("match-by", "_id", `Match documents by "_id", or "_index,_id" to match them by index and ID, e.g. for index patterns with the same ID in several indices`)
+		missingKey              = flag.String("missing-key", "error", `What to do with documents without the fields of -replace-with: "error", "skip", or "report" to skip and print them to stderr`)
+		unsorted                = flag.Bool("unsorted", false, `Compare documents regardless of their order, e.g. when sorting by another field with -ssort and -dsort`)
+		slices                  = flag.Int("slices", 1, `Number of slices to read in parallel from both source and destination`)
+		iterateStrategy         = flag.String("strategy", "auto", `Strategy for iterating over the documents: "scroll", "pit" (point in time), or "auto" to use point in time if supported`)
+		maxCreated              = flag.Int64("max-created", -1, `Maximum number of created docs before failing, or -1 for no limit`)
+		maxUpdated              = flag.Int64("max-updated", -1, `Maximum number of updated docs before failing, or -1 for no limit`)
+		maxDeleted              = flag.Int64("max-deleted", -1, `Maximum number of deleted docs before failing, or -1 for no limit`)
+		maxDiffRatio            = flag.Float64("max-diff-ratio", -1, `Maximum ratio of created, updated and deleted docs to all docs before failing, e.g. 0.01, or -1 for no limit`)
+		summary                 = flag.Bool("summary", false, `Print a summary to stderr at the end`)
+		summaryOnly             = flag.Bool("summary-only", false, `Print only a summary to stdout, but no documents`)
 	)
 
-	log.SetFlags(0)
+	cfgOpts := configFlags(flag.CommandLine)
+	diffOpts := diffFlags(flag.CommandLine)
+
 	flag.Usage = usage
 	flag.Parse()
 
 	if flag.NArg() != 2 {
 		usage()
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	var srcFilterIncludes []string
@@ -59,13 +108,28 @@ func main() {
 		srcFilterExcludes = strings.Split(*srcFilterExclude, ",")
 	}
 
+	strategy, err := elastic.ParseIterateStrategy(*iterateStrategy)
+	if err != nil {
+		fatal(err)
+	}
+
+	keyIndex, err := parseMatchBy(*matchBy)
+	if err != nil {
+		fatal(err)
+	}
+	missingKeyPolicy, err := elastic.ParseMissingKeyPolicy(*missingKey)
+	if err != nil {
+		fatal(err)
+	}
+	var missingKeys int64
+
 	options := []elastic.ClientOption{
 		elastic.WithBatchSize(*size),
 	}
 
-	src, err := newClient(flag.Arg(0), options...)
+	src, err := newClient(flag.Arg(0), cfgOpts(), options...)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	srcIterReq := &elastic.IterateRequest{
 		RawQuery:            *rawSrcQuery,
@@ -73,10 +137,16 @@ func main() {
 		ReplaceField:        *replaceWithAnotherField,
 		SourceFilterInclude: srcFilterIncludes,
 		SourceFilterExclude: srcFilterExcludes,
+		Strategy:            strategy,
+		Slices:              *slices,
+		Unordered:           *unsorted,
+		KeyIndex:            keyIndex,
+		MissingKey:          missingKeyPolicy,
+		OnMissingKey:        reportMissingKey("source", &missingKeys),
 	}
-	dst, err := newClient(flag.Arg(1), options...)
+	dst, err := newClient(flag.Arg(1), cfgOpts(), options...)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	dstIterReq := &elastic.IterateRequest{
 		RawQuery:            *rawDstQuery,
@@ -84,6 +154,12 @@ func main() {
 		ReplaceField:        *replaceWithAnotherField,
 		SourceFilterInclude: srcFilterIncludes,
 		SourceFilterExclude: srcFilterExcludes,
+		Strategy:            strategy,
+		Slices:              *slices,
+		Unordered:           *unsorted,
+		KeyIndex:            keyIndex,
+		MissingKey:          missingKeyPolicy,
+		OnMissingKey:        reportMissingKey("destination", &missingKeys),
 	}
 	var p printer.Printer
 	{
@@ -92,13 +168,33 @@ func main() {
 			p = printer.NewStdPrinter(os.Stdout, *unchanged, *updated, *changed, *deleted)
 		case "json":
 			p = printer.NewJSONPrinter(os.Stdout, *unchanged, *updated, *changed, *deleted)
+		case "jsonpatch":
+			p = printer.NewJSONPatchPrinter(os.Stdout, *unchanged, *updated, *changed, *deleted)
+		case "bulk":
+			if *replaceWithAnotherField != "" {
+				// The bulk actions need the _id, not the replacement
+				fatal("-o=bulk cannot be used with -replace-with")
+			}
+			p = printer.NewBulkPrinter(os.Stdout, keyIndex, *updated, *changed, *deleted)
 		}
 	}
 
+	diffOptions, err := diffOpts()
+	if err != nil {
+		fatal(err)
+	}
+	differ := diff.Differ
+	if *unsorted {
+		differ = diff.HashDiffer
+	}
+
+	var stats diff.Stats
+	start := time.Now()
+
 	g, ctx := errgroup.WithContext(context.Background())
 	srcDocCh, srcErrCh := src.Iterate(ctx, srcIterReq)
 	dstDocCh, dstErrCh := dst.Iterate(ctx, dstIterReq)
-	diffCh, errCh := diff.Differ(ctx, srcDocCh, dstDocCh)
+	diffCh, errCh := differ(ctx, srcDocCh, dstDocCh, diffOptions...)
 	g.Go(func() error {
 		for {
 			select {
@@ -106,6 +202,10 @@ func main() {
 				if !ok {
 					return nil
 				}
+				stats.Add(d)
+				if *summaryOnly {
+					continue
+				}
 				if err := p.Print(d); err != nil {
 					return err
 				}
@@ -124,28 +224,246 @@ func main() {
 		return <-errCh
 	})
 	if err = g.Wait(); err != nil {
-		log.Fatal(err)
+		fatal(differError(err))
+	}
+	stats.Elapsed = time.Since(start)
+	if missingKeys > 0 {
+		log.Printf("skipped %d documents without a key", missingKeys)
+	}
+
+	if *summary || *summaryOnly {
+		w := os.Stderr
+		if *summaryOnly {
+			w = os.Stdout
+		}
+		printSummary := printer.PrintSummary
+		if *outputFormat == "json" || *outputFormat == "jsonpatch" {
+			printSummary = printer.PrintJSONSummary
+		}
+		if err := printSummary(w, &stats); err != nil {
+			fatal(err)
+		}
+	}
+
+	thresholds := diff.Thresholds{
+		MaxCreated:   *maxCreated,
+		MaxUpdated:   *maxUpdated,
+		MaxDeleted:   *maxDeleted,
+		MaxDiffRatio: *maxDiffRatio,
+	}
+	if !thresholds.Enabled() {
+		// Without thresholds, every difference is a failure
+		if stats.Differences() > 0 || missingKeys > 0 {
+			os.Exit(exitDifferent)
+		}
+		os.Exit(exitIdentical)
+	}
+	if exceeded := thresholds.Exceeded(&stats); len(exceeded) > 0 {
+		for _, msg := range exceeded {
+			log.Print(msg)
+		}
+		os.Exit(exitDifferent)
+	}
+	if missingKeys > 0 {
+		os.Exit(exitDifferent)
+	}
+	os.Exit(exitIdentical)
+}
+
+// parseMatchBy returns true if the -match-by flag includes the index of
+// the documents, i.e. for "_index,_id", and false for "_id".
+func parseMatchBy(matchBy string) (bool, error) {
+	switch strings.ReplaceAll(matchBy, " ", "") {
+	case "", "_id":
+		return false, nil
+	case "_index,_id":
+		return true, nil
+	default:
+		return false, errors.Errorf(`unknown -match-by %q, use "_id" or "_index,_id"`, matchBy)
 	}
 }
 
+// reportMissingKey returns a func for elastic.IterateRequest.OnMissingKey
+// that prints the documents of side without a key to stderr and counts
+// them in n.
+func reportMissingKey(side string, n *int64) func(*diff.Document) {
+	return func(doc *diff.Document) {
+		atomic.AddInt64(n, 1)
+		log.Printf("document %s in %s has no key", doc.ID, side)
+	}
+}
+
+// differError adds a hint to use -unsorted to errors of diff.Differ
+// caused by documents that are not sorted by ID.
+func differError(err error) error {
+	var sortErr diff.SortOrderError
+	if errors.As(err, &sortErr) {
+		return errors.Errorf("%v; use -unsorted to compare documents regardless of their order", err)
+	}
+	return err
+}
+
+// fatal logs v and exits with exitError.
+func fatal(v ...interface{}) {
+	log.Print(v...)
+	os.Exit(exitError)
+}
+
+// fatalf logs a formatted message and exits with exitError.
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(exitError)
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "General usage:\n\n")
-	fmt.Fprintf(os.Stderr, "\t%s [flags] <source-url> <destination-url>\n\n", path.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "\t%s [flags] <source-url> <destination-url>\n", path.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "\t%s dump [flags] <source-url> <file>\n", path.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "\t%s mapping [flags] <source-url> <destination-url>\n", path.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "\t%s settings [flags] <source-url> <destination-url>\n", path.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "\t%s sync [flags] <source-url> <destination-url>\n\n", path.Base(os.Args[0]))
 	fmt.Fprintf(os.Stderr, "General flags:\n")
 	flag.PrintDefaults()
 }
 
-// newClient will create a new Elasticsearch client,
-// matching the supported version.
-func newClient(url string, opts ...elastic.ClientOption) (elastic.Client, error) {
-	cfg, err := config.Parse(url)
+// configFlags registers the flags for connecting to a cluster with fs.
+// The returned function returns the configuration options for the flags,
+// which serve as defaults for the settings in the URLs.
+func configFlags(fs *flag.FlagSet) func() []config.Option {
+	var (
+		caCert   = fs.String("cacert", "", `Path to a PEM file with CA certificates to trust, e.g. "/etc/ssl/ca.pem"`)
+		cert     = fs.String("cert", "", `Path to a PEM file with a client certificate for TLS authentication`)
+		key      = fs.String("key", "", `Path to a PEM file with the private key of the client certificate`)
+		insecure = fs.Bool("insecure", false, `Skip verification of the server certificate`)
+		apiKey   = fs.String("apikey", "", `API key to authenticate with, e.g. "env:ES_API_KEY" to read it from the environment or "file:/path/to/key" to read it from a file`)
+		bearer   = fs.String("bearer", "", `Bearer token to authenticate with, e.g. "env:ES_TOKEN" or "file:/path/to/token"`)
+		headers  headerFlags
+
+		awsSigV4   = fs.Bool("aws-sigv4", false, `Sign requests with AWS Signature Version 4, e.g. for Amazon OpenSearch Service`)
+		awsRegion  = fs.String("aws-region", "", `AWS region to sign requests for (implies -aws-sigv4), e.g. "eu-central-1" (default from environment or shared config)`)
+		awsService = fs.String("aws-service", "", `AWS service to sign requests for: "es" or "aoss" for OpenSearch Serverless (default "es")`)
+		awsProfile = fs.String("aws-profile", "", `AWS profile to read credentials and region from (default from AWS_PROFILE or "default")`)
+	)
+	fs.Var(&headers, "header", `Header to add to every request, e.g. "X-Tenant: acme" (can be repeated)`)
+	return func() []config.Option {
+		opts := []config.Option{
+			config.WithCACert(*caCert),
+			config.WithClientCert(*cert, *key),
+			config.WithInsecure(*insecure),
+			config.WithAPIKey(*apiKey),
+			config.WithBearerToken(*bearer),
+			config.WithAWSSigV4(*awsSigV4, *awsRegion, *awsService, *awsProfile),
+		}
+		for _, h := range headers {
+			name, value, _ := config.ParseHeader(h)
+			opts = append(opts, config.WithHeader(name, value))
+		}
+		return opts
+	}
+}
+
+// headerFlags is a flag for headers that can be repeated.
+type headerFlags []string
+
+func (f *headerFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *headerFlags) Set(value string) error {
+	if _, _, err := config.ParseHeader(value); err != nil {
+		return err
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// differFunc is the signature of diff.Differ and diff.HashDiffer.
+type differFunc func(context.Context, <-chan *diff.Document, <-chan *diff.Document, ...diff.Option) (<-chan diff.Diff, <-chan error)
+
+// diffFlags registers the flags for comparing documents with fs. The
+// returned function returns the diff options for the flags.
+func diffFlags(fs *flag.FlagSet) func() ([]diff.Option, error) {
+	var (
+		maxInMemory    = fs.Int("max-in-memory", 100000, `Number of unmatched documents to keep in memory with -unsorted before spilling to $TMPDIR`)
+		ignorePaths    = fs.String("ignore", "", `Fields to ignore when comparing documents, e.g. "@timestamp,_meta.version,**.ingested_at"`)
+		epsilon        = fs.Float64("epsilon", 0, `Tolerance for comparing numbers, e.g. 0.0001`)
+		epsilonPaths   = fs.String("epsilon-paths", "", `Fields to apply the tolerance to, e.g. "price,*.amount" (default all fields)`)
+		coercePaths    = fs.String("coerce", "", `Fields to compare strings and numbers by value, e.g. "id,**.count", or "**" for all fields`)
+		unorderedPaths = fs.String("unordered", "", `Fields with arrays to compare regardless of the order of their elements, e.g. "tags,**.labels", or "**" for all fields`)
+		arrayKeys      = fs.String("array-key", "", `Fields with arrays of objects to compare by matching their elements by a key field, e.g. "items=sku,variants=id"`)
+		datePaths      = fs.String("dates", "", `Fields to compare as dates regardless of their format, e.g. "created,**.*_at", or "**" for all fields`)
+	)
+	return func() ([]diff.Option, error) {
+		opts := []diff.Option{diff.WithMaxInMemory(*maxInMemory)}
+		if *ignorePaths != "" {
+			opts = append(opts, diff.WithIgnorePaths(strings.Split(*ignorePaths, ",")...))
+		}
+		if *epsilon > 0 {
+			var paths []string
+			if *epsilonPaths != "" {
+				paths = strings.Split(*epsilonPaths, ",")
+			}
+			opts = append(opts, diff.WithNumericTolerance(*epsilon, paths...))
+		}
+		if *coercePaths != "" {
+			opts = append(opts, diff.WithCoercion(strings.Split(*coercePaths, ",")...))
+		}
+		if *datePaths != "" {
+			opts = append(opts, diff.WithDateNormalization(strings.Split(*datePaths, ",")...))
+		}
+		if *unorderedPaths != "" {
+			opts = append(opts, diff.WithUnorderedArrays(strings.Split(*unorderedPaths, ",")...))
+		}
+		if *arrayKeys != "" {
+			for _, s := range strings.Split(*arrayKeys, ",") {
+				parts := strings.SplitN(s, "=", 2)
+				if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					return nil, errors.Errorf("invalid array key %q: expected <field>=<key>", s)
+				}
+				opts = append(opts, diff.WithArrayKey(parts[1], parts[0]))
+			}
+		}
+		return opts, nil
+	}
+}
+
+// newClient will create a new Elasticsearch or OpenSearch client,
+// matching the supported distribution and version. URLs with the
+// file scheme create a client that reads from an NDJSON file.
+func newClient(url string, cfgOpts []config.Option, opts ...elastic.ClientOption) (elastic.Client, error) {
+	if file.IsFileURL(url) {
+		c, err := file.NewClient(url)
+		if err != nil {
+			return nil, err
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+		return c, nil
+	}
+	cfg, err := config.Parse(url, cfgOpts...)
 	if err != nil {
 		return nil, err
 	}
-	v, major, _, _, err := elasticsearchVersion(cfg)
+	distribution, v, major, _, _, err := elasticsearchVersion(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if distribution == "opensearch" {
+		switch major {
+		case 1, 2, 7: // OpenSearch reports 7.10.2 in compatibility mode
+			c, err := opensearch.NewClient(cfg)
+			if err != nil {
+				return nil, err
+			}
+			for _, opt := range opts {
+				opt(c)
+			}
+			return c, nil
+		default:
+			return nil, errors.Errorf("unsupported OpenSearch version %s", v)
+		}
+	}
 	switch major {
 	case 5:
 		c, err := v5.NewClient(cfg)
@@ -174,38 +492,60 @@ func newClient(url string, opts ...elastic.ClientOption) (elastic.Client, error)
 			opt(c)
 		}
 		return c, nil
+	case 8:
+		c, err := v8.NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+		return c, nil
 	default:
 		return nil, errors.Errorf("unsupported Elasticsearch version %s", v)
 	}
 }
 
-// elasticsearchVersion determines the Elasticsearch option.
-func elasticsearchVersion(cfg *config.Config) (string, int64, int64, int64, error) {
+// elasticsearchVersion determines the distribution (e.g. "opensearch",
+// or empty for Elasticsearch) and version of the cluster.
+func elasticsearchVersion(cfg *config.Config) (string, string, int64, int64, int64, error) {
 	type infoType struct {
 		Name    string `json:"name"`
 		Version struct {
-			Number string `json:"number"` // e.g. "6.2.4"
+			Distribution string `json:"distribution"` // e.g. "opensearch"
+			Number       string `json:"number"`       // e.g. "6.2.4"
 		} `json:"version"`
 	}
 	req, err := http.NewRequest("GET", cfg.URL, nil)
 	if err != nil {
-		return "", 0, 0, 0, err
+		return "", "", 0, 0, 0, err
 	}
 	if cfg.Username != "" || cfg.Password != "" {
 		req.SetBasicAuth(cfg.Username, cfg.Password)
 	}
-	res, err := http.DefaultClient.Do(req)
+	httpClient, err := transport.NewClient(cfg)
+	if err != nil {
+		return "", "", 0, 0, 0, err
+	}
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return "", 0, 0, 0, err
+		return "", "", 0, 0, 0, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		return "", "", 0, 0, 0, errors.Errorf("unable to determine Elasticsearch version of %s: authentication required", cfg.URL)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", "", 0, 0, 0, errors.Errorf("unable to determine Elasticsearch version of %s: %s", cfg.URL, res.Status)
+	}
 	var info infoType
 	if err = json.NewDecoder(res.Body).Decode(&info); err != nil {
-		return "", 0, 0, 0, err
+		return "", "", 0, 0, 0, err
 	}
+	distribution := strings.ToLower(info.Version.Distribution)
 	v, err := semver.NewVersion(info.Version.Number)
 	if err != nil {
-		return info.Version.Number, 0, 0, 0, err
+		return distribution, info.Version.Number, 0, 0, 0, err
 	}
-	return info.Version.Number, v.Major(), v.Minor(), v.Patch(), nil
+	return distribution, info.Version.Number, v.Major(), v.Minor(), v.Patch(), nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/opensearch"
+	v5 "github.com/olivere/esdiff/elastic/v5"
+	v6 "github.com/olivere/esdiff/elastic/v6"
+	v7 "github.com/olivere/esdiff/elastic/v7"
+	v8 "github.com/olivere/esdiff/elastic/v8"
+)
+
+// newInfoServer returns a server that responds to GET / with the
+// info of a cluster with the given distribution and version.
+func newInfoServer(t *testing.T, distribution, number string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if distribution == "" {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		}
+		fmt.Fprintf(w, `{"name":"node","version":{"distribution":%q,"number":%q}}`, distribution, number)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestElasticsearchVersion(t *testing.T) {
+	tests := []struct {
+		Distribution string
+		Number       string
+		Major        int64
+	}{
+		{"", "6.8.23", 6},
+		{"", "7.17.9", 7},
+		{"", "8.11.1", 8},
+		{"opensearch", "1.3.14", 1},
+		{"opensearch", "2.11.0", 2},
+		{"opensearch", "7.10.2", 7},
+	}
+	for i, tt := range tests {
+		ts := newInfoServer(t, tt.Distribution, tt.Number)
+		cfg, err := config.Parse(ts.URL + "/index01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		distribution, v, major, _, _, err := elasticsearchVersion(cfg)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if distribution != tt.Distribution {
+			t.Errorf("#%d: want distribution %q, have %q", i, tt.Distribution, distribution)
+		}
+		if v != tt.Number {
+			t.Errorf("#%d: want version %q, have %q", i, tt.Number, v)
+		}
+		if major != tt.Major {
+			t.Errorf("#%d: want major version %d, have %d", i, tt.Major, major)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		Distribution string
+		Number       string
+		Want         string // type of the client, or empty for an error
+	}{
+		{"", "5.6.16", fmt.Sprintf("%T", &v5.Client{})},
+		{"", "6.8.23", fmt.Sprintf("%T", &v6.Client{})},
+		{"", "7.17.9", fmt.Sprintf("%T", &v7.Client{})},
+		{"", "8.11.1", fmt.Sprintf("%T", &v8.Client{})},
+		{"", "9.0.0", ""},
+		{"opensearch", "1.3.14", fmt.Sprintf("%T", &opensearch.Client{})},
+		{"opensearch", "2.11.0", fmt.Sprintf("%T", &opensearch.Client{})},
+		// OpenSearch in compatibility mode still reports its distribution
+		{"opensearch", "7.10.2", fmt.Sprintf("%T", &opensearch.Client{})},
+		{"opensearch", "3.0.0", ""},
+	}
+	for i, tt := range tests {
+		ts := newInfoServer(t, tt.Distribution, tt.Number)
+		c, err := newClient(ts.URL+"/index01", nil)
+		if tt.Want == "" {
+			if err == nil {
+				t.Errorf("#%d: want error for %s %s, have %T", i, tt.Distribution, tt.Number, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if have := fmt.Sprintf("%T", c); have != tt.Want {
+			t.Errorf("#%d: want %s for %s %s, have %s", i, tt.Want, tt.Distribution, tt.Number, have)
+		}
+	}
+}
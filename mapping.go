@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/diff/printer"
+	"github.com/olivere/esdiff/elastic"
+)
+
+// runMapping implements the mapping command, which compares the mappings
+// of source and destination index field by field.
+func runMapping(args []string) {
+	fs := flag.NewFlagSet("mapping", flag.ExitOnError)
+	var (
+		outputFormat = fs.String("o", "", "Output format, e.g. json")
+		ignorePaths  = fs.String("ignore", "", `Fields to ignore, e.g. "@timestamp,_meta.*,**.keyword"`)
+	)
+	cfgOpts := configFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Mapping usage:\n\n")
+		fmt.Fprintf(os.Stderr, "\t%s mapping [flags] <source-url> <destination-url>\n\n", path.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Mapping flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	var opts []diff.Option
+	if *ignorePaths != "" {
+		opts = append(opts, diff.WithIgnorePaths(strings.Split(*ignorePaths, ",")...))
+	}
+
+	var mappings [2]map[string]interface{}
+	g, ctx := errgroup.WithContext(context.Background())
+	for i := range mappings {
+		i := i
+		g.Go(func() error {
+			c, err := newClient(fs.Arg(i), cfgOpts())
+			if err != nil {
+				return err
+			}
+			mc, ok := c.(elastic.MappingClient)
+			if !ok {
+				return errors.Errorf("%s does not support mappings", fs.Arg(i))
+			}
+			mappings[i], err = mc.Mapping(ctx)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		fatal(err)
+	}
+
+	printDiff := printer.PrintFieldDiff
+	if *outputFormat == "json" {
+		printDiff = printer.PrintJSONFieldDiff
+	}
+	diffs := diff.CompareMappings(mappings[0], mappings[1], opts...)
+	for _, d := range diffs {
+		if err := printDiff(os.Stdout, d); err != nil {
+			fatal(err)
+		}
+	}
+	if len(diffs) > 0 {
+		os.Exit(exitDifferent)
+	}
+}
@@ -2,6 +2,8 @@
 curl -H 'Content-Type: application/json' -XDELETE 'localhost:19200/index01'
 curl -H 'Content-Type: application/json' -XDELETE 'localhost:29200/index01'
 curl -H 'Content-Type: application/json' -XDELETE 'localhost:39200/index01'
+curl -H 'Content-Type: application/json' -XDELETE 'localhost:49200/index01'
+curl -H 'Content-Type: application/json' -XDELETE 'localhost:52200/index01'
 
 # Create mappings
 curl -X PUT "localhost:19200/index01" -H 'Content-Type: application/json' -d'
@@ -41,6 +43,28 @@ curl -X PUT "localhost:39200/index01" -H 'Content-Type: application/json' -d'
 }
 '
 
+curl -X PUT "localhost:49200/index01" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "user":    { "type": "keyword" },
+      "message": { "type": "keyword" }
+    }
+  }
+}
+'
+
+curl -X PUT "localhost:52200/index01" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "user":    { "type": "keyword" },
+      "message": { "type": "keyword" }
+    }
+  }
+}
+'
+
 # Add documents
 curl -H 'Content-Type: application/json' -XPUT 'localhost:19200/index01/tweet/1' -d '{"user":"olivere","message":"Welcome to Golang"}'
 curl -H 'Content-Type: application/json' -XPUT 'localhost:19200/index01/tweet/2' -d '{"user":"olivere","message":"Running is fun"}'
@@ -53,3 +77,11 @@ curl -H 'Content-Type: application/json' -XPUT 'localhost:29200/index01/_doc/4'
 curl -H 'Content-Type: application/json' -XPUT 'localhost:39200/index01/_doc/1' -d '{"user":"olivere","message":"Welcome to Golang"}'
 curl -H 'Content-Type: application/json' -XPUT 'localhost:39200/index01/_doc/3' -d '{"user":"sandrae","message":"Playing the flute, oh boy"}'
 curl -H 'Content-Type: application/json' -XPUT 'localhost:39200/index01/_doc/5' -d '{"user":"sandrae","message":"Ran that marathon"}'
+
+curl -H 'Content-Type: application/json' -XPUT 'localhost:49200/index01/_doc/1' -d '{"user":"olivere","message":"Welcome to Golang"}'
+curl -H 'Content-Type: application/json' -XPUT 'localhost:49200/index01/_doc/3' -d '{"user":"sandrae","message":"Playing the flute, oh boy"}'
+curl -H 'Content-Type: application/json' -XPUT 'localhost:49200/index01/_doc/6' -d '{"user":"sandrae","message":"Swam across the lake"}'
+
+curl -H 'Content-Type: application/json' -XPUT 'localhost:52200/index01/_doc/1' -d '{"user":"olivere","message":"Welcome to Golang"}'
+curl -H 'Content-Type: application/json' -XPUT 'localhost:52200/index01/_doc/3' -d '{"user":"sandrae","message":"Playing the flute, oh boy"}'
+curl -H 'Content-Type: application/json' -XPUT 'localhost:52200/index01/_doc/5' -d '{"user":"sandrae","message":"Ran that marathon"}'
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/diff/printer"
+	"github.com/olivere/esdiff/elastic"
+)
+
+// volatileSettings are the settings that differ between any two indices,
+// e.g. because they are set when an index is created.
+var volatileSettings = []string{
+	"settings.index.uuid",
+	"settings.index.creation_date",
+	"settings.index.creation_date_string",
+	"settings.index.version",
+	"settings.index.provided_name",
+	"settings.index.history.uuid",
+	"settings.index.resize",
+	"settings.index.routing.allocation.initial_recovery",
+}
+
+// runSettings implements the settings command, which compares the settings
+// and aliases of source and destination index, and the index templates
+// that apply to them.
+func runSettings(args []string) {
+	fs := flag.NewFlagSet("settings", flag.ExitOnError)
+	var (
+		outputFormat = fs.String("o", "", "Output format, e.g. json")
+		ignorePaths  = fs.String("ignore", "", `Settings to ignore in addition to volatile ones like the uuid, e.g. "settings.index.number_of_replicas,aliases.*"`)
+		templates    = fs.String("templates", "", `Names of index templates to compare, e.g. "logs-*" (default templates that apply to the indices)`)
+	)
+	cfgOpts := configFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Settings usage:\n\n")
+		fmt.Fprintf(os.Stderr, "\t%s settings [flags] <source-url> <destination-url>\n\n", path.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Settings flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	ignore := append([]string(nil), volatileSettings...)
+	if *ignorePaths != "" {
+		ignore = append(ignore, strings.Split(*ignorePaths, ",")...)
+	}
+
+	var settings [2]*elastic.IndexSettings
+	g, ctx := errgroup.WithContext(context.Background())
+	for i := range settings {
+		i := i
+		g.Go(func() error {
+			c, err := newClient(fs.Arg(i), cfgOpts())
+			if err != nil {
+				return err
+			}
+			sc, ok := c.(elastic.SettingsClient)
+			if !ok {
+				return errors.Errorf("%s does not support settings", fs.Arg(i))
+			}
+			settings[i], err = sc.IndexSettings(ctx)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		fatal(err)
+	}
+
+	var names []string
+	if *templates != "" {
+		names = strings.Split(*templates, ",")
+	}
+	src, dst := settings[0], settings[1]
+	src.Templates, dst.Templates = filterTemplates(src.Templates, dst.Templates, names, src.Index, dst.Index)
+	src.IndexTemplates, dst.IndexTemplates = filterTemplates(src.IndexTemplates, dst.IndexTemplates, names, src.Index, dst.Index)
+
+	printChange := printer.PrintChange
+	if *outputFormat == "json" {
+		printChange = printer.PrintJSONChange
+	}
+	changes := diff.Compare(settingsDocument(src), settingsDocument(dst), diff.WithIgnorePaths(ignore...))
+	for _, c := range changes {
+		if err := printChange(os.Stdout, c); err != nil {
+			fatal(err)
+		}
+	}
+	if len(changes) > 0 {
+		os.Exit(exitDifferent)
+	}
+}
+
+// settingsDocument returns s as a document to compare.
+func settingsDocument(s *elastic.IndexSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"settings":        s.Settings,
+		"aliases":         s.Aliases,
+		"templates":       s.Templates,
+		"index_templates": s.IndexTemplates,
+	}
+}
+
+// filterTemplates returns the templates of source and destination that
+// match one of names. If names is empty, it returns the templates that
+// apply to either the source index or the destination index.
+func filterTemplates(src, dst map[string]interface{}, names []string, srcIndex, dstIndex string) (map[string]interface{}, map[string]interface{}) {
+	keep := make(map[string]bool)
+	for _, templates := range []map[string]interface{}{src, dst} {
+		for name, t := range templates {
+			if len(names) > 0 {
+				keep[name] = keep[name] || matchAny(names, name)
+			} else {
+				patterns := elastic.TemplatePatterns(t)
+				keep[name] = keep[name] || matchAny(patterns, srcIndex) || matchAny(patterns, dstIndex)
+			}
+		}
+	}
+	filter := func(templates map[string]interface{}) map[string]interface{} {
+		m := make(map[string]interface{})
+		for name, t := range templates {
+			if keep[name] {
+				m[name] = t
+			}
+		}
+		return m
+	}
+	return filter(src), filter(dst)
+}
+
+// matchAny returns true if s matches one of the wildcard patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/diff/printer"
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/config"
+	"github.com/olivere/esdiff/elastic/file"
+)
+
+// runSync implements the sync command, which makes the destination index
+// match the source by sending the differences to the Bulk API of the
+// destination: Deleted and updated documents are indexed with their
+// source, and created documents are deleted.
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	var (
+		size            = fs.Int("size", 100, "Batch size for reading")
+		slices          = fs.Int("slices", 1, `Number of slices to read in parallel from both source and destination`)
+		matchBy         = fs.String("match-by", "_id", `Match documents by "_id", or "_index,_id" to match them by index and ID, e.g. for index patterns with the same ID in several indices`)
+		unsorted        = fs.Bool("unsorted", false, `Compare documents regardless of their order`)
+		iterateStrategy = fs.String("strategy", "auto", `Strategy for iterating over the documents: "scroll", "pit" (point in time), or "auto" to use point in time if supported`)
+		bulkSize        = fs.Int("bulk-size", 500, "Number of actions per bulk request")
+		concurrency     = fs.Int("concurrency", 1, "Number of bulk requests to send in parallel")
+		refresh         = fs.String("refresh", "", `Refresh policy of the bulk requests: "true", "false" or "wait_for" (default the policy of the cluster)`)
+		dryRun          = fs.Bool("dry-run", false, "Print the bulk requests to stdout instead of sending them")
+		deleteDocs      = fs.Bool("delete", true, "Delete documents that only exist in the destination")
+		summary         = fs.Bool("summary", false, `Print a summary of the differences to stderr at the end`)
+	)
+	cfgOpts := configFlags(fs)
+	diffOpts := diffFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Sync usage:\n\n")
+		fmt.Fprintf(os.Stderr, "\t%s sync [flags] <source-url> <destination-url>\n\n", path.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Sync flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitError)
+	}
+
+	strategy, err := elastic.ParseIterateStrategy(*iterateStrategy)
+	if err != nil {
+		fatal(err)
+	}
+	keyIndex, err := parseMatchBy(*matchBy)
+	if err != nil {
+		fatal(err)
+	}
+	if err := checkSyncDestination(fs.Arg(1), keyIndex, cfgOpts()); err != nil {
+		fatal(err)
+	}
+	refreshPolicy, err := elastic.ParseRefresh(*refresh)
+	if err != nil {
+		fatal(err)
+	}
+	diffOptions, err := diffOpts()
+	if err != nil {
+		fatal(err)
+	}
+	differ := diff.Differ
+	if *unsorted {
+		differ = diff.HashDiffer
+	}
+	if *bulkSize <= 0 {
+		fatal("-bulk-size must be positive")
+	}
+	if *concurrency <= 0 {
+		fatal("-concurrency must be positive")
+	}
+
+	src, err := newClient(fs.Arg(0), cfgOpts(), elastic.WithBatchSize(*size))
+	if err != nil {
+		fatal(err)
+	}
+	dst, err := newClient(fs.Arg(1), cfgOpts(), elastic.WithBatchSize(*size))
+	if err != nil {
+		fatal(err)
+	}
+
+	s := &syncer{
+		bulkSize:    *bulkSize,
+		concurrency: *concurrency,
+		refresh:     refreshPolicy,
+		deleteDocs:  *deleteDocs,
+		keyIndex:    keyIndex,
+	}
+	if *dryRun {
+		s.printer = printer.NewBulkPrinter(os.Stdout, keyIndex, true, true, true)
+	} else {
+		bc, ok := dst.(elastic.BulkClient)
+		if !ok {
+			fatalf("%s does not support writing", fs.Arg(1))
+		}
+		s.dst = bc
+	}
+
+	start := time.Now()
+	srcIterReq := &elastic.IterateRequest{Strategy: strategy, Slices: *slices, Unordered: *unsorted, KeyIndex: keyIndex}
+	dstIterReq := &elastic.IterateRequest{Strategy: strategy, Slices: *slices, Unordered: *unsorted, KeyIndex: keyIndex}
+	err = s.run(context.Background(), differ, src, srcIterReq, dst, dstIterReq, diffOptions)
+	s.stats.Elapsed = time.Since(start)
+	if err != nil {
+		fatal(differError(err))
+	}
+
+	if *summary {
+		if err := printer.PrintSummary(os.Stderr, &s.stats); err != nil {
+			fatal(err)
+		}
+	}
+	for _, f := range s.notFound {
+		log.Printf("document %s to delete was not found", f.ID)
+	}
+	for _, f := range s.failed {
+		log.Printf("failed to %s document %s: [%d] %s", f.Op, f.ID, f.Status, f.Reason)
+	}
+	if !*dryRun {
+		log.Printf("indexed %d, deleted %d, not found %d and failed %d documents", s.indexed, s.deleted, len(s.notFound), len(s.failed))
+	}
+	if len(s.failed) > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// checkSyncDestination makes sure that documents which only exist in the
+// source can be written to the destination. A comma-separated list of
+// indices or an index pattern has no index to write them to, so documents
+// need to be matched by index and ID to write them to the index of the
+// same name as in the source.
+func checkSyncDestination(url string, keyIndex bool, cfgOpts []config.Option) error {
+	if keyIndex || file.IsFileURL(url) {
+		return nil
+	}
+	cfg, err := config.Parse(url, cfgOpts...)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(cfg.Index, "*,") {
+		return errors.Errorf("destination %s is a list of indices or an index pattern: use -match-by=_index,_id to sync it", cfg.Index)
+	}
+	return nil
+}
+
+// syncer sends the bulk actions for the differences between source and
+// destination to the destination, or prints them in a dry run.
+type syncer struct {
+	dst         elastic.BulkClient
+	printer     printer.Printer
+	bulkSize    int
+	concurrency int
+	refresh     string
+	deleteDocs  bool
+	keyIndex    bool
+
+	stats diff.Stats
+
+	mu       sync.Mutex
+	indexed  int
+	deleted  int
+	notFound []elastic.BulkFailure
+	failed   []elastic.BulkFailure
+}
+
+// run compares the documents of src and dst and syncs the differences.
+func (s *syncer) run(ctx context.Context, differ differFunc, src elastic.Client, srcReq *elastic.IterateRequest, dst elastic.Client, dstReq *elastic.IterateRequest, opts []diff.Option) error {
+	g, ctx := errgroup.WithContext(ctx)
+	srcDocCh, srcErrCh := src.Iterate(ctx, srcReq)
+	dstDocCh, dstErrCh := dst.Iterate(ctx, dstReq)
+	diffCh, errCh := differ(ctx, srcDocCh, dstDocCh, opts...)
+
+	batchCh := make(chan []diff.BulkAction)
+	g.Go(func() error {
+		defer close(batchCh)
+		var batch []diff.BulkAction
+		for {
+			select {
+			case d, ok := <-diffCh:
+				if !ok {
+					if len(batch) > 0 {
+						return s.send(ctx, batchCh, batch)
+					}
+					return nil
+				}
+				s.stats.Add(d)
+				if d.Mode == diff.Created && !s.deleteDocs {
+					continue
+				}
+				if s.printer != nil {
+					if err := s.printer.Print(d); err != nil {
+						return err
+					}
+					continue
+				}
+				action, ok := diff.NewBulkAction(d, s.keyIndex)
+				if !ok {
+					continue
+				}
+				batch = append(batch, action)
+				if len(batch) >= s.bulkSize {
+					if err := s.send(ctx, batchCh, batch); err != nil {
+						return err
+					}
+					batch = nil
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	for i := 0; i < s.concurrency; i++ {
+		g.Go(func() error {
+			for batch := range batchCh {
+				if err := s.bulk(ctx, batch); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		return <-srcErrCh
+	})
+	g.Go(func() error {
+		return <-dstErrCh
+	})
+	g.Go(func() error {
+		return <-errCh
+	})
+	return g.Wait()
+}
+
+// send passes batch to the workers.
+func (s *syncer) send(ctx context.Context, batchCh chan<- []diff.BulkAction, batch []diff.BulkAction) error {
+	select {
+	case batchCh <- batch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bulk sends batch to the destination and records the outcome.
+func (s *syncer) bulk(ctx context.Context, batch []diff.BulkAction) error {
+	res, err := s.dst.Bulk(ctx, &elastic.BulkRequest{
+		Actions: batch,
+		Refresh: s.refresh,
+	})
+	if err != nil {
+		return errors.Wrap(err, "bulk request failed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexed += res.Indexed
+	s.deleted += res.Deleted
+	s.notFound = append(s.notFound, res.NotFound...)
+	s.failed = append(s.failed, res.Failed...)
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/olivere/esdiff/diff"
+	"github.com/olivere/esdiff/elastic"
+	"github.com/olivere/esdiff/elastic/config"
+	v8 "github.com/olivere/esdiff/elastic/v8"
+)
+
+// bulkAction is an action as received by a bulkServer.
+type bulkAction struct {
+	Op, Index, ID, Routing string
+}
+
+// bulkServer records the requests to the Bulk API of an index. If block
+// is positive, the first block requests wait until all of them arrived.
+// Deleting the documents with an ID in missing returns 404.
+type bulkServer struct {
+	t       *testing.T
+	block   int
+	missing map[string]bool
+
+	mu       sync.Mutex
+	requests [][]bulkAction
+	arrived  chan struct{}
+	ready    chan struct{}
+}
+
+func newBulkServer(t *testing.T, block int) (*bulkServer, *v8.Client) {
+	t.Helper()
+	s := &bulkServer{t: t, block: block, arrived: make(chan struct{}, block), ready: make(chan struct{})}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	if block > 0 {
+		go func() {
+			for i := 0; i < block; i++ {
+				<-s.arrived
+			}
+			close(s.ready)
+		}()
+	}
+	cfg, err := config.Parse(ts.URL + "/index01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := v8.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func (s *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path != "/index01/_bulk" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var actions []bulkAction
+	var items []map[string]interface{}
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		var meta map[string]struct {
+			Index   string `json:"_index"`
+			ID      string `json:"_id"`
+			Routing string `json:"routing"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &meta); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for op, m := range meta {
+			actions = append(actions, bulkAction{Op: op, Index: m.Index, ID: m.ID, Routing: m.Routing})
+			status := http.StatusOK
+			if op == "delete" && s.missing[m.ID] {
+				status = http.StatusNotFound
+			}
+			items = append(items, map[string]interface{}{op: map[string]interface{}{"_id": m.ID, "status": status}})
+			if op == "index" {
+				sc.Scan() // skip source
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, actions)
+	n := len(s.requests)
+	s.mu.Unlock()
+	if n <= s.block {
+		s.arrived <- struct{}{}
+		select {
+		case <-s.ready:
+		case <-time.After(5 * time.Second):
+			s.t.Errorf("want %d bulk requests in parallel, have %d", s.block, n)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+}
+
+// newDocs returns documents with the given IDs and messages.
+func newDocs(index string, idAndMessages ...string) []*diff.Document {
+	var docs []*diff.Document
+	for _, s := range idAndMessages {
+		parts := strings.SplitN(s, ":", 2)
+		docs = append(docs, &diff.Document{
+			ID:     parts[0],
+			Index:  index,
+			Source: map[string]interface{}{"message": parts[1]},
+		})
+	}
+	return docs
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		DeleteDocs bool
+		Want       []bulkAction
+	}{
+		{
+			DeleteDocs: true,
+			Want: []bulkAction{
+				{Op: "index", ID: "2"},
+				{Op: "index", ID: "3"},
+				{Op: "delete", ID: "4"},
+			},
+		},
+		{
+			DeleteDocs: false,
+			Want: []bulkAction{
+				{Op: "index", ID: "2"},
+				{Op: "index", ID: "3"},
+			},
+		},
+	}
+	for i, tt := range tests {
+		server, client := newBulkServer(t, 0)
+		src := &sliceClient{docs: newDocs("", "1:One", "2:Two", "3:Three")}
+		dst := &sliceClient{docs: newDocs("", "1:One", "2:Zwei", "4:Four")}
+		s := &syncer{dst: client, bulkSize: 100, concurrency: 1, deleteDocs: tt.DeleteDocs}
+		err := s.run(context.Background(), diff.Differ, src, &elastic.IterateRequest{}, dst, &elastic.IterateRequest{}, nil)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if len(server.requests) != 1 {
+			t.Fatalf("#%d: want 1 bulk request, have %d", i, len(server.requests))
+		}
+		if !cmp.Equal(tt.Want, server.requests[0]) {
+			t.Fatalf("#%d: %v", i, cmp.Diff(tt.Want, server.requests[0]))
+		}
+		if want, have := 2, s.indexed; want != have {
+			t.Errorf("#%d: want %d indexed, have %d", i, want, have)
+		}
+		if want, have := len(tt.Want)-2, s.deleted; want != have {
+			t.Errorf("#%d: want %d deleted, have %d", i, want, have)
+		}
+		if want, have := int64(1), s.stats.Unchanged; want != have {
+			t.Errorf("#%d: want %d unchanged, have %d", i, want, have)
+		}
+	}
+}
+
+func TestSyncKeyIndex(t *testing.T) {
+	server, client := newBulkServer(t, 0)
+	src := &sliceClient{docs: append(newDocs("logs-1", "1:One", "2:Two"), newDocs("logs-2", "1:Uno")...)}
+	dst := &sliceClient{docs: append(newDocs("logs-1", "2:Zwei"), newDocs("logs-3", "1:Eins")...)}
+	req := &elastic.IterateRequest{KeyIndex: true}
+	for _, d := range append(append([]*diff.Document(nil), src.docs...), dst.docs...) {
+		d.Key = d.Index + "\x00" + d.ID
+	}
+	s := &syncer{dst: client, bulkSize: 100, concurrency: 1, deleteDocs: true, keyIndex: true}
+	if err := s.run(context.Background(), diff.Differ, src, req, dst, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bulkAction{{
+		{Op: "index", Index: "logs-1", ID: "1"},
+		{Op: "index", Index: "logs-1", ID: "2"},
+		{Op: "index", Index: "logs-2", ID: "1"},
+		{Op: "delete", Index: "logs-3", ID: "1"},
+	}}
+	if !cmp.Equal(want, server.requests) {
+		t.Fatal(cmp.Diff(want, server.requests))
+	}
+}
+
+func TestSyncRouting(t *testing.T) {
+	server, client := newBulkServer(t, 0)
+	server.missing = map[string]bool{"4": true}
+	src := &sliceClient{docs: newDocs("", "1:One", "2:Two", "3:Three")}
+	dst := &sliceClient{docs: newDocs("", "1:One", "2:Zwei", "4:Four", "5:Five")}
+	src.docs[1].Routing = "r2"
+	src.docs[2].Routing = "r3"
+	dst.docs[1].Routing = "r2"
+	dst.docs[2].Routing = "r4"
+	dst.docs[3].Routing = "r5"
+	s := &syncer{dst: client, bulkSize: 100, concurrency: 1, deleteDocs: true}
+	err := s.run(context.Background(), diff.Differ, src, &elastic.IterateRequest{}, dst, &elastic.IterateRequest{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bulkAction{{
+		{Op: "index", ID: "2", Routing: "r2"},
+		{Op: "index", ID: "3", Routing: "r3"},
+		{Op: "delete", ID: "4", Routing: "r4"},
+		{Op: "delete", ID: "5", Routing: "r5"},
+	}}
+	if !cmp.Equal(want, server.requests) {
+		t.Fatal(cmp.Diff(want, server.requests))
+	}
+	if want, have := 2, s.indexed; want != have {
+		t.Errorf("want %d indexed, have %d", want, have)
+	}
+	// A document to delete that doesn't exist is not deleted
+	if want, have := 1, s.deleted; want != have {
+		t.Errorf("want %d deleted, have %d", want, have)
+	}
+	wantNotFound := []elastic.BulkFailure{{Op: diff.BulkDelete, ID: "4", Status: http.StatusNotFound}}
+	if !cmp.Equal(wantNotFound, s.notFound) {
+		t.Errorf("%v", cmp.Diff(wantNotFound, s.notFound))
+	}
+	if len(s.failed) > 0 {
+		t.Errorf("want no failures, have %v", s.failed)
+	}
+}
+
+func TestSyncBatches(t *testing.T) {
+	var srcIDs []string
+	for i := 1; i <= 7; i++ {
+		srcIDs = append(srcIDs, fmt.Sprintf("%d:Message %d", i, i))
+	}
+	tests := []struct {
+		BulkSize    int
+		Concurrency int
+		Want        []int // sizes of the bulk requests
+	}{
+		{BulkSize: 100, Concurrency: 1, Want: []int{7}},
+		{BulkSize: 3, Concurrency: 1, Want: []int{3, 3, 1}},
+		{BulkSize: 1, Concurrency: 1, Want: []int{1, 1, 1, 1, 1, 1, 1}},
+		{BulkSize: 2, Concurrency: 4, Want: []int{2, 2, 2, 1}},
+	}
+	for i, tt := range tests {
+		block := 0
+		if tt.Concurrency > 1 {
+			block = tt.Concurrency
+		}
+		server, client := newBulkServer(t, block)
+		src := &sliceClient{docs: newDocs("", srcIDs...)}
+		dst := &sliceClient{}
+		s := &syncer{dst: client, bulkSize: tt.BulkSize, concurrency: tt.Concurrency, deleteDocs: true}
+		err := s.run(context.Background(), diff.Differ, src, &elastic.IterateRequest{}, dst, &elastic.IterateRequest{}, nil)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		var sizes []int
+		var ids []string
+		for _, actions := range server.requests {
+			sizes = append(sizes, len(actions))
+			for _, action := range actions {
+				ids = append(ids, action.ID)
+			}
+		}
+		// Parallel requests arrive in any order
+		sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+		sort.Strings(ids)
+		if !cmp.Equal(tt.Want, sizes) {
+			t.Errorf("#%d: %v", i, cmp.Diff(tt.Want, sizes))
+		}
+		if want := []string{"1", "2", "3", "4", "5", "6", "7"}; !cmp.Equal(want, ids) {
+			t.Errorf("#%d: %v", i, cmp.Diff(want, ids))
+		}
+		if want, have := 7, s.indexed; want != have {
+			t.Errorf("#%d: want %d indexed, have %d", i, want, have)
+		}
+	}
+}
+
+func TestCheckSyncDestination(t *testing.T) {
+	tests := []struct {
+		URL      string
+		KeyIndex bool
+		Err      bool
+	}{
+		{URL: "http://localhost:9200/index01", Err: false},
+		{URL: "http://localhost:9200/logs-2024", Err: false},
+		{URL: "http://localhost:9200/logs-2024.*", Err: true},
+		{URL: "http://localhost:9200/logs-2024.*", KeyIndex: true, Err: false},
+		{URL: "http://localhost:9200/index01,index02", Err: true},
+		{URL: "http://localhost:9200/index01,index02/_doc", KeyIndex: true, Err: false},
+		{URL: "file://dump.ndjson", Err: false},
+	}
+	for i, tt := range tests {
+		err := checkSyncDestination(tt.URL, tt.KeyIndex, nil)
+		if tt.Err && err == nil {
+			t.Errorf("#%d: want error for %s", i, tt.URL)
+		}
+		if !tt.Err && err != nil {
+			t.Errorf("#%d: want no error for %s, have %v", i, tt.URL, err)
+		}
+	}
+}